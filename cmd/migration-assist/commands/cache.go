@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/migration-assist/internal/cache"
+	"github.com/mattermost/migration-assist/internal/git"
+	"github.com/mattermost/migration-assist/internal/logger"
+)
+
+func CacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manages the local cache of the migrations cloned from the Mattermost repository",
+	}
+
+	cmd.AddCommand(CacheListCmd())
+	cmd.AddCommand(CachePruneCmd())
+
+	return cmd
+}
+
+func CacheListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Lists the cached migration sets",
+		RunE:  runCacheListCmdF,
+	}
+}
+
+func CachePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Removes the cached migration sets",
+		RunE:    runCachePruneCmdF,
+		Example: "  migration-assist cache prune --mattermost-version v9.11 \\\n--older-than 720h",
+	}
+
+	cmd.Flags().String("mattermost-version", "", "Only removes the migrations of the given Mattermost version")
+	cmd.Flags().Duration("older-than", 0, "Only removes the migrations cached before the given duration, the entries without a manifest are aged by the modification time of their directory")
+	cmd.Flags().Bool("invalid-only", false, "Only removes the migrations failing the checksum verification")
+
+	return cmd
+}
+
+func runCacheListCmdF(cmd *cobra.Command, _ []string) error {
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		return fmt.Errorf("could not list cached migrations: %w", err)
+	}
+
+	if len(entries) == 0 {
+		cmd.Printf("no migrations cached in %s\n", c.Root())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDRIVER\tFILES\tSIZE\tDIGEST\tCACHED AT\tSTATUS")
+	for _, e := range entries {
+		status := "ok"
+		if e.Err != nil {
+			status = e.Err.Error()
+		}
+
+		digest := e.Digest
+		if len(digest) > 12 {
+			digest = digest[:12]
+		}
+
+		var cachedAt string
+		if !e.CreatedAt.IsZero() {
+			cachedAt = e.CreatedAt.Local().Format(time.DateTime)
+		}
+
+		fmt.Fprintf(w, "v%s\t%s\t%d\t%d\t%s\t%s\t%s\n", e.Version, e.Driver, e.Files, e.Size, digest, cachedAt, status)
+	}
+
+	return w.Flush()
+}
+
+func runCachePruneCmdF(cmd *cobra.Command, _ []string) error {
+	baseLogger := logger.NewLogger(os.Stderr, logger.Options{Timestamps: true})
+
+	c, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	var version *semver.Version
+	if mmVersion, _ := cmd.Flags().GetString("mattermost-version"); mmVersion != "" {
+		v, err2 := semver.ParseTolerant(mmVersion)
+		if err2 != nil {
+			return fmt.Errorf("could not parse version: %w", err2)
+		}
+		version = &v
+	}
+	olderThan, _ := cmd.Flags().GetDuration("older-than")
+	invalidOnly, _ := cmd.Flags().GetBool("invalid-only")
+
+	entries, err := c.List()
+	if err != nil {
+		return fmt.Errorf("could not list cached migrations: %w", err)
+	}
+
+	var removed int
+	for _, e := range entries {
+		if version != nil && !version.EQ(e.Version) {
+			continue
+		}
+		// the entries without a manifest, e.g. partial downloads, are aged
+		// by the modification time of their directory.
+		cachedAt := e.CreatedAt
+		if cachedAt.IsZero() {
+			cachedAt = e.ModTime
+		}
+		if olderThan > 0 && time.Since(cachedAt) < olderThan {
+			continue
+		}
+		if invalidOnly && e.Err == nil {
+			continue
+		}
+
+		if err = c.Remove(e.Version, e.Driver); err != nil {
+			return fmt.Errorf("could not remove %s@v%s: %w", e.Driver, e.Version, err)
+		}
+		baseLogger.Printf("removed %s migrations of v%s\n", e.Driver, e.Version)
+		removed++
+	}
+
+	baseLogger.Printf("%d cached migration set(s) removed.\n", removed)
+
+	return nil
+}
+
+func openCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.New(dir), nil
+}
+
+// cachedMigrations returns the directory containing the migrations of the given
+// Mattermost version and driver. The repository is only cloned if the migrations
+// are not cached yet or the cached files fail the checksum verification.
+func cachedMigrations(v semver.Version, driver string, baseLogger, verboseLogger logger.LogInterface) (string, error) {
+	c, err := openCache()
+	if err != nil {
+		return "", err
+	}
+
+	dir := c.Path(v, driver)
+	err = c.Verify(v, driver)
+	switch {
+	case err == nil:
+		baseLogger.Printf("using cached migrations from %s\n", dir)
+		return dir, nil
+	case errors.Is(err, cache.ErrNotCached):
+		verboseLogger.Printf("no cached migrations found for %s@%s\n", driver, v.String())
+	default:
+		baseLogger.Printf("cached migrations are invalid, they will be cloned again: %s\n", err)
+	}
+
+	if err = c.Remove(v, driver); err != nil {
+		return "", fmt.Errorf("could not clear cached migrations: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "mattermost")
+	if err != nil {
+		return "", fmt.Errorf("could not create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	baseLogger.Printf("cloning %s@%s\n", "repository", v.String())
+	err = git.CloneMigrations(git.CloneOptions{
+		TempRepoPath: tempDir,
+		Output:       dir,
+		DriverType:   driver,
+		Version:      v,
+	}, verboseLogger)
+	if err != nil {
+		return "", fmt.Errorf("error during cloning migrations: %w", err)
+	}
+
+	m, err := c.Seal(v, driver)
+	if err != nil {
+		return "", fmt.Errorf("could not seal cached migrations: %w", err)
+	}
+	verboseLogger.Printf("%d migration files cached in %s (digest: %s)\n", len(m.Files), dir, m.Digest)
+
+	return dir, nil
+}
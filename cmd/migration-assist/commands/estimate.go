@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/migration-assist/internal/estimate"
+	"github.com/mattermost/migration-assist/internal/logger"
+	"github.com/mattermost/migration-assist/internal/pgloader"
+	"github.com/mattermost/migration-assist/internal/store"
+	"github.com/mattermost/migration-assist/queries"
+)
+
+var createIndexTableRegex = regexp.MustCompile(`(?i)CREATE\s+INDEX\s+.*?\s+ON\s+(\w+)`)
+
+func EstimateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "estimate",
+		Short:   "Estimates the duration of the migration and the disk usage of the target database",
+		RunE:    runEstimateCmdF,
+		Example: "  migration-assist estimate \"root:mostest@tcp(localhost:3306)/mattermost_test\"",
+		Args:    cobra.MinimumNArgs(1),
+	}
+
+	model := estimate.DefaultModel()
+	cmd.Flags().Int64("rows-per-second", model.RowsPerSecond, "Rows loaded per second by each pgLoader worker")
+	cmd.Flags().Int64("load-mb-per-second", model.BytesPerSecond>>20, "Megabytes loaded per second by each pgLoader worker")
+	cmd.Flags().Int64("index-mb-per-second", model.IndexBytesPerSecond>>20, "Megabytes indexed per second while creating the full text indexes")
+
+	return cmd
+}
+
+func runEstimateCmdF(cmd *cobra.Command, args []string) error {
+	baseLogger := logger.NewLogger(os.Stderr, logger.Options{Timestamps: true})
+
+	mysqlDB, err := store.NewStore("mysql", args[0])
+	if err != nil {
+		return err
+	}
+	defer mysqlDB.Close()
+
+	baseLogger.Println("pinging mysql...")
+	if err = mysqlDB.Ping(); err != nil {
+		return fmt.Errorf("could not ping mysql: %w", err)
+	}
+
+	sizes, err := mysqlDB.GetTableSizes(cmd.Context(), pgloader.ExcludedTables)
+	if err != nil {
+		return err
+	}
+
+	model := estimate.DefaultModel()
+	model.RowsPerSecond, _ = cmd.Flags().GetInt64("rows-per-second")
+	loadMB, _ := cmd.Flags().GetInt64("load-mb-per-second")
+	model.BytesPerSecond = loadMB << 20
+	indexMB, _ := cmd.Flags().GetInt64("index-mb-per-second")
+	model.IndexBytesPerSecond = indexMB << 20
+
+	e, err := estimateMigration(model, sizes)
+	if err != nil {
+		return err
+	}
+
+	printEstimate(os.Stdout, e)
+	baseLogger.Println("the row counts of InnoDB tables are approximate, the estimate is a rough guide only.")
+
+	return nil
+}
+
+// estimateMigration applies the model to the tables with the settings of the
+// pgloader configuration templates and the post-migrate indexes.
+func estimateMigration(model estimate.Model, sizes []store.TableSize) (estimate.Estimate, error) {
+	settings := make(map[string]pgloader.Settings)
+	for _, product := range []string{"", "boards", "playbooks", "calls"} {
+		s, err := pgloader.TemplateSettings(product)
+		if err != nil {
+			return estimate.Estimate{}, fmt.Errorf("could not read the pgloader template settings: %w", err)
+		}
+		settings[product] = s
+	}
+
+	fulltextIndexes, err := postMigrateIndexTables()
+	if err != nil {
+		return estimate.Estimate{}, err
+	}
+
+	return model.Estimate(sizes, settings, fulltextIndexes), nil
+}
+
+// postMigrateIndexTables returns the tables indexed by the post-migrate
+// queries.
+func postMigrateIndexTables() ([]string, error) {
+	assets := queries.Assets()
+
+	entries, err := assets.ReadDir("post-migrate")
+	if err != nil {
+		return nil, fmt.Errorf("could not read post-migrate queries: %w", err)
+	}
+
+	var tables []string
+	for _, e := range entries {
+		b, err2 := assets.ReadFile(path.Join("post-migrate", e.Name()))
+		if err2 != nil {
+			return nil, fmt.Errorf("could not read embedded sql file: %w", err2)
+		}
+		for _, m := range createIndexTableRegex.FindAllStringSubmatch(string(b), -1) {
+			tables = append(tables, m[1])
+		}
+	}
+
+	return tables, nil
+}
+
+func printEstimate(w io.Writer, e estimate.Estimate) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tROWS\tDATA\tINDEX\tLOAD\tPOST-MIGRATE\tTARGET SIZE")
+	for _, t := range e.Tables {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", t.Table, t.Rows, formatBytes(t.DataLength), formatBytes(t.IndexLength), formatDuration(t.Load), formatDuration(t.Index), formatBytes(t.TargetSize))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nestimated load duration:         %s\n", formatDuration(e.Load))
+	fmt.Fprintf(w, "estimated post-migrate duration: %s\n", formatDuration(e.Index))
+	fmt.Fprintf(w, "estimated total downtime:        %s\n", formatDuration(e.Downtime()))
+	fmt.Fprintf(w, "estimated target disk usage:     %s\n", formatBytes(e.TargetSize))
+	fmt.Fprintf(w, "required free space:             %s\n", formatBytes(e.RequiredSpace()))
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseBytes parses a size such as 512M or 200GB, the units are powers of 1024.
+// A number without a unit is in bytes.
+func parseBytes(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	var exp int
+	if unit := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(s[i:]), "B"), "I"); unit != "" {
+		exp = strings.Index("KMGTPE", unit) + 1
+		if len(unit) > 1 || exp == 0 {
+			return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+		}
+	}
+
+	return int64(n * math.Pow(1024, float64(exp))), nil
+}
@@ -0,0 +1,41 @@
+package commands
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "512", want: 512},
+		{input: "512B", want: 512},
+		{input: "1k", want: 1 << 10},
+		{input: "512M", want: 512 << 20},
+		{input: "200GB", want: 200 << 30},
+		{input: "1.5GiB", want: 3 << 29},
+		{input: " 2 TB ", want: 2 << 40},
+		{input: "", wantErr: true},
+		{input: "GB", wantErr: true},
+		{input: "10XB", wantErr: true},
+		{input: "10MGB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseBytes(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseBytes() = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBytes() error = %v, want no error", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
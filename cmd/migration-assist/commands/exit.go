@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/mattermost/migration-assist/internal/store"
+)
+
+const (
+	// ExitCodeFixesRequired is used if any of the checks require a fix which
+	// has not been applied.
+	ExitCodeFixesRequired = 2
+	// ExitCodeFixesFailed is used if any of the requested fixes failed.
+	ExitCodeFixesFailed = 3
+)
+
+// ExitError is returned by the commands to exit with a specific code other
+// than the generic error code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// printCheckSummary writes a table of the results of all of the checks.
+func printCheckSummary(w io.Writer, results []store.CheckResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tCHECK\tCOUNT\tSTATUS")
+	for _, r := range results {
+		status := checkStatus(r)
+		if r.Strategy != "" {
+			status += " (" + r.Strategy + ")"
+		}
+		if r.Note != "" {
+			status += " (" + r.Note + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", r.Category, r.Name, r.Count, status)
+	}
+	tw.Flush()
+}
+
+func checkStatus(r store.CheckResult) string {
+	switch {
+	case r.FixError != "":
+		return "fix failed"
+	case r.Fixed:
+		return "fixed"
+	case r.Count > 0:
+		return "fix required"
+	default:
+		return "ok"
+	}
+}
+
+// checkResultsError returns an *ExitError if any of the checks require a fix
+// or any of the fixes failed.
+func checkResultsError(results []store.CheckResult) error {
+	var required, failed int
+	for _, r := range results {
+		switch checkStatus(r) {
+		case "fix failed":
+			failed++
+		case "fix required":
+			required++
+		}
+	}
+
+	switch {
+	case failed > 0:
+		return &ExitError{Code: ExitCodeFixesFailed, Err: fmt.Errorf("%d fix(es) failed", failed)}
+	case required > 0:
+		return &ExitError{Code: ExitCodeFixesRequired, Err: fmt.Errorf("%d fix(es) required", required)}
+	default:
+		return nil
+	}
+}
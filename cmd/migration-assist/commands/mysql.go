@@ -3,12 +3,16 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/spf13/cobra"
@@ -16,13 +20,21 @@ import (
 
 	module "github.com/testcontainers/testcontainers-go/modules/mysql"
 
-	"github.com/mattermost/migration-assist/internal/git"
 	"github.com/mattermost/migration-assist/internal/logger"
+	"github.com/mattermost/migration-assist/internal/pgloader"
 	"github.com/mattermost/migration-assist/internal/store"
 	"github.com/mattermost/migration-assist/queries"
 	"github.com/mattermost/morph/sources/file"
 )
 
+const (
+	// procedureCleanupTimeout is the time allowed to drop the procedures
+	// once the command exits.
+	procedureCleanupTimeout = 30 * time.Second
+)
+
+var createProcedureRegex = regexp.MustCompile(`(?i)CREATE\s+PROCEDURE\s+` + "`?" + `(\w+)`)
+
 func SourceCheckCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "mysql",
@@ -34,12 +46,27 @@ func SourceCheckCmd() *cobra.Command {
 
 	// Optional flags
 	cmd.Flags().Bool("fix-artifacts", false, "Removes the artifacts from older versions of Mattermost")
-	cmd.Flags().Bool("fix-varchar", false, "Removes the rows with varchar overflow")
+	cmd.Flags().Bool("fix-varchar", false, "Fixes the values longer than the varchar limits of Postgres with --varchar-strategy")
+	cmd.Flags().StringSlice("varchar-strategy", nil, "Strategy to fix the varchar overflows with, one of delete, truncate or skip, optionally per table or column (e.g. truncate,audits=delete,posts.type=skip)")
+	cmd.Flags().String("postgres-migrations-dir", "", "Postgres migrations directory to read the varchar limits from (defaults to the migrations embedded into the tool)")
+	cmd.Flags().Bool("fix-dates", false, "Sets the zero dates to NULL or to the lowest valid date if the column is not nullable")
+	cmd.Flags().Bool("fix-json", false, "Replaces the invalid JSON values with an empty object or array (implies --check-json)")
+	cmd.Flags().Bool("fix-orphans", false, "Removes the rows referencing missing channels, posts, teams or users (implies --check-orphans)")
 	cmd.Flags().Bool("fix-unicode", false, "Removes the unsupported unicode characters from MySQL tables")
+	cmd.Flags().Bool("check-collations", false, "Checks the unique indexes for values colliding under the byte-wise comparison of Postgres (reads all of the indexed rows)")
+	cmd.Flags().Bool("check-orphans", false, "Checks for the rows referencing missing channels, posts, teams or users (reads all of the rows of these tables)")
+	cmd.Flags().Bool("check-json", false, "Checks the columns loaded as jsonb for invalid JSON values (reads all of the rows of these columns)")
+	cmd.Flags().Bool("check-encoding", false, "Scans the text columns for byte sequences that are not valid UTF-8 (reads all of the rows)")
+	cmd.Flags().Bool("fix-encoding", false, "Replaces the byte sequences that are not valid UTF-8 (implies --check-encoding)")
+	cmd.Flags().String("encoding-replacement", "\uFFFD", "Replacement for the byte sequences that are not valid UTF-8, an empty value strips them")
+	cmd.Flags().Bool("check-null-chars", false, "Scans the text columns for NUL bytes (reads all of the rows)")
+	cmd.Flags().Bool("fix-null-chars", false, "Removes the NUL bytes from the text columns (implies --check-null-chars)")
+	cmd.Flags().Bool("no-procedures", false, "Runs the checks and fixes with plain queries instead of stored procedures (e.g. for read-only replicas)")
+	cmd.Flags().Bool("check-preflight", true, "Check if the privileges and settings of the MySQL user are suitable for the checks and the requested fixes")
 	cmd.Flags().Bool("full-schema-check", false, "Checks the MySQL schema to determine whether it's in desired state")
 	cmd.Flags().Bool("save-diff", false, "Writes diffs to files")
 	cmd.Flags().String("migrations-dir", "", "Migrations directory (should be used if mattermost-version is not supplied)")
-	cmd.Flags().String("mattermost-version", "v9.7", "Mattermost version to be cloned to run migrations")
+	cmd.Flags().String("mattermost-version", "", "Mattermost version to be cloned to run migrations (detected from the database if not supplied)")
 	cmd.Flags().String("output", "mysql.output", "Output file for the applied migrations, postgres subcommands will use this file to apply the migrations")
 
 	return cmd
@@ -69,97 +96,299 @@ func runSourceCheckCmdF(cmd *cobra.Command, args []string) error {
 	}
 	baseLogger.Println("connected to mysql successfully...")
 
-	applied, err := mysqlDB.GetAppliedMigrations(cmd.Context())
+	fixArtifacts, _ := cmd.Flags().GetBool("fix-artifacts")
+	fixUnicode, _ := cmd.Flags().GetBool("fix-unicode")
+	fixVarchar, _ := cmd.Flags().GetBool("fix-varchar")
+	fixJSON, _ := cmd.Flags().GetBool("fix-json")
+	fixOrphans, _ := cmd.Flags().GetBool("fix-orphans")
+	noProcedures, _ := cmd.Flags().GetBool("no-procedures")
+	postgresMigrationsDir, _ := cmd.Flags().GetString("postgres-migrations-dir")
+
+	strategyValues, _ := cmd.Flags().GetStringSlice("varchar-strategy")
+	varcharStrategies, err := store.ParseVarcharStrategies(strategyValues)
 	if err != nil {
-		return fmt.Errorf("could not get applied migrations: %w", err)
+		return err
 	}
-	mysqlConfig := store.DBConfig{
-		AppliedMigrations: applied,
+
+	checkPreflight, _ := cmd.Flags().GetBool("check-preflight")
+	if checkPreflight {
+		var fixes []string
+		if fixArtifacts {
+			fixes = append(fixes, "artifacts")
+		}
+		if fixUnicode {
+			fixes = append(fixes, "unicode")
+		}
+		if fixJSON {
+			fixes = append(fixes, "json")
+		}
+		if fixOrphans {
+			fixes = append(fixes, "orphans")
+		}
+
+		// the applied migrations are not known yet, the tables of every
+		// migration are included.
+		var extraPrivileges []store.TablePrivilege
+		if fixVarchar {
+			columns, err2 := getVarcharColumns(cmd.Context(), mysqlDB, postgresMigrationsDir, nil)
+			if err2 != nil {
+				return err2
+			}
+			extraPrivileges = append(extraPrivileges, store.VarcharFixPrivileges(columns, varcharStrategies)...)
+		}
+
+		// the values to fix are not known before the scan, every table with
+		// a text column may be updated.
+		fixEncoding, _ := cmd.Flags().GetBool("fix-encoding")
+		fixNullChars, _ := cmd.Flags().GetBool("fix-null-chars")
+		if fixEncoding || fixNullChars {
+			columns, err2 := mysqlDB.GetTextColumns(cmd.Context())
+			if err2 != nil {
+				return err2
+			}
+			extraPrivileges = append(extraPrivileges, store.EncodingFixPrivileges(columns)...)
+		}
+
+		err = runMySQLPreflight(cmd.Context(), mysqlDB, fixes, extraPrivileges, noProcedures, baseLogger)
+		if err != nil {
+			return err
+		}
 	}
 
-	b, err := json.MarshalIndent(mysqlConfig, "", "    ")
+	applied, err := mysqlDB.GetAppliedMigrations(cmd.Context())
 	if err != nil {
-		return fmt.Errorf("could not marshal mysql config: %w", err)
+		return fmt.Errorf("could not get applied migrations: %w", err)
 	}
 
 	outputFile, _ := cmd.Flags().GetString("output")
 	if _, err = os.Stat(outputFile); err == nil || os.IsExist(err) {
-		if ConfirmationPrompt("Output file already exists, do you want to overwrite it?") {
-			if err = os.Remove(outputFile); err != nil {
-				return fmt.Errorf("could not remove output file: %w", err)
-			}
-		} else {
-			baseLogger.Println("Output file already exists, will not overwrite it.")
+		// the existing file is replaced only once the new one is written.
+		if !Confirm(cmd, "Output file already exists, do you want to overwrite it?") {
+			outputFile = alternateOutputFile(outputFile, time.Now())
+			baseLogger.Printf("Output file already exists, will write to %s instead.\n", outputFile)
 		}
 	}
-	if err = os.WriteFile(outputFile, b, 0666); err != nil {
-		return fmt.Errorf("could not write to output file: %w", err)
-	}
 
 	fullSchema, _ := cmd.Flags().GetBool("full-schema-check")
 	if fullSchema {
 		mmVersion, _ := cmd.Flags().GetString("mattermost-version")
-		v, err2 := semver.ParseTolerant(mmVersion)
-		if err2 != nil {
-			return fmt.Errorf("could not parse version: %w", err2)
-		}
+		migrationsDir, _ := cmd.Flags().GetString("migrations-dir")
 
-		tempDir, err3 := os.MkdirTemp("", "mattermost")
-		if err3 != nil {
-			return fmt.Errorf("could not create temp directory: %w", err3)
+		var v semver.Version
+		switch {
+		case mmVersion != "":
+			v, err = semver.ParseTolerant(mmVersion)
+			if err != nil {
+				return fmt.Errorf("could not parse version: %w", err)
+			}
+		case migrationsDir == "":
+			v, err = detectMattermostVersion(cmd.Context(), mysqlDB, baseLogger)
+			if err != nil {
+				return err
+			}
 		}
-
-		migrationsDir, _ := cmd.Flags().GetString("migrations-dir")
 		saveDiff, _ := cmd.Flags().GetBool("save-diff")
 
-		err = runFullSchemaCheck(mysqlDB, migrationsDir, tempDir, v, baseLogger, verboseLogger, saveDiff)
+		err = runFullSchemaCheck(mysqlDB, migrationsDir, v, baseLogger, verboseLogger, saveDiff)
 		if err != nil {
 			return fmt.Errorf("error during full schema check: %w", err)
 		}
 	}
 
 	// create procedures
-	cleanUpFn, err := createProcedures(mysqlDB, baseLogger)
-	if err != nil {
-		return fmt.Errorf("error during creating procedures for mysql: %w", err)
+	if noProcedures {
+		baseLogger.Println("procedures are not created, running the checks with plain queries.")
+	} else {
+		cleanUpFn, err2 := createProcedures(cmd.Context(), mysqlDB, baseLogger)
+		if err2 != nil {
+			return fmt.Errorf("error during creating procedures for mysql: %w", err2)
+		}
+		defer cleanUpFn()
 	}
-	defer cleanUpFn()
 
 	// run MySQL schema checks
-	fixArtifacts, _ := cmd.Flags().GetBool("fix-artifacts")
-
-	err = runChecksForMySQL(mysqlDB, "artifacts", fixArtifacts, baseLogger, verboseLogger)
+	var results []store.CheckResult
+	res, err := runChecksForMySQL(cmd.Context(), mysqlDB, "artifacts", fixArtifacts, noProcedures, baseLogger, verboseLogger)
+	results = append(results, res...)
 	if err != nil {
 		return fmt.Errorf("error during running artifact checks for mysql: %w", err)
 	}
 
-	fixUnicode, _ := cmd.Flags().GetBool("fix-unicode")
-
-	if err = checkMySQLDBVersion(mysqlDB, baseLogger, fixUnicode); err != nil {
+	if err = checkMySQLDBVersion(cmd.Context(), mysqlDB, baseLogger, fixUnicode); err != nil {
 		return fmt.Errorf("error during checking MySQL version: %w", err)
 	}
 
-	err = runChecksForMySQL(mysqlDB, "unicode", fixUnicode, baseLogger, verboseLogger)
+	res, err = runChecksForMySQL(cmd.Context(), mysqlDB, "unicode", fixUnicode, noProcedures, baseLogger, verboseLogger)
+	results = append(results, res...)
 	if err != nil {
 		return fmt.Errorf("error during running unicode checks for mysql: %w", err)
 	}
 
-	fixVarchar, _ := cmd.Flags().GetBool("fix-varchar")
+	checkOrphans, _ := cmd.Flags().GetBool("check-orphans")
+	if checkOrphans || fixOrphans {
+		res, err = runOrphanChecks(cmd.Context(), mysqlDB, fixOrphans, noProcedures, baseLogger, verboseLogger)
+		results = append(results, res...)
+		if err != nil {
+			return fmt.Errorf("error during running orphan checks for mysql: %w", err)
+		}
+	}
+
+	fixDates, _ := cmd.Flags().GetBool("fix-dates")
 
-	err = runChecksForMySQL(mysqlDB, "varchar", fixVarchar, baseLogger, verboseLogger)
+	res, err = runDateChecks(cmd.Context(), mysqlDB, fixDates, baseLogger, verboseLogger)
+	results = append(results, res...)
 	if err != nil {
-		return fmt.Errorf("error during running varchar checks for mysql: %w", err)
+		return fmt.Errorf("error during running date checks for mysql: %w", err)
 	}
 
-	err = runChecksForMySQL(mysqlDB, "varchar-extended", fixVarchar, baseLogger, verboseLogger)
+	checkCollations, _ := cmd.Flags().GetBool("check-collations")
+	if checkCollations {
+		res, err = runCollationChecks(cmd.Context(), mysqlDB, baseLogger, verboseLogger)
+		results = append(results, res...)
+		if err != nil {
+			return fmt.Errorf("error during running collation checks for mysql: %w", err)
+		}
+	}
+
+	checkEncoding, _ := cmd.Flags().GetBool("check-encoding")
+	fixEncoding, _ := cmd.Flags().GetBool("fix-encoding")
+	if checkEncoding || fixEncoding {
+		replacement, _ := cmd.Flags().GetString("encoding-replacement")
+
+		res, err = runEncodingChecks(cmd.Context(), mysqlDB, fixEncoding, replacement, baseLogger, verboseLogger)
+		results = append(results, res...)
+		if err != nil {
+			return fmt.Errorf("error during running encoding checks for mysql: %w", err)
+		}
+	}
+
+	checkNullChars, _ := cmd.Flags().GetBool("check-null-chars")
+	fixNullChars, _ := cmd.Flags().GetBool("fix-null-chars")
+	if checkNullChars || fixNullChars {
+		res, err = runNullCharChecks(cmd.Context(), mysqlDB, fixNullChars, baseLogger, verboseLogger)
+		results = append(results, res...)
+		if err != nil {
+			return fmt.Errorf("error during running null character checks for mysql: %w", err)
+		}
+	}
+
+	checkJSON, _ := cmd.Flags().GetBool("check-json")
+	if checkJSON || fixJSON {
+		res, err = runJSONChecks(cmd.Context(), mysqlDB, fixJSON, noProcedures, baseLogger, verboseLogger)
+		results = append(results, res...)
+		if err != nil {
+			return fmt.Errorf("error during running json checks for mysql: %w", err)
+		}
+	}
+
+	varcharColumns, err := getVarcharColumns(cmd.Context(), mysqlDB, postgresMigrationsDir, applied)
+	if err != nil {
+		return err
+	}
+
+	res, err = runVarcharChecks(cmd.Context(), mysqlDB, varcharColumns, fixVarchar, varcharStrategies, baseLogger, verboseLogger)
+	results = append(results, res...)
 	if err != nil {
 		return fmt.Errorf("error during running varchar checks for mysql: %w", err)
 	}
 
+	err = writeMySQLOutput(cmd.Context(), mysqlDB, outputFile, applied, results, verboseLogger)
+	if err != nil {
+		return err
+	}
+	baseLogger.Printf("applied migrations are written to %s\n", outputFile)
+
+	printCheckSummary(os.Stderr, results)
+
+	return checkResultsError(results)
+}
+
+// alternateOutputFile returns a timestamped path next to the output file, e.g.
+// mysql.20240102T150405.output for mysql.output.
+func alternateOutputFile(outputFile string, t time.Time) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "." + t.Format("20060102T150405") + ext
+}
+
+// writeMySQLOutput writes the applied migrations along with the provenance of
+// the source database into the output file.
+func writeMySQLOutput(ctx context.Context, db *store.DB, outputFile string, applied []int, checks []store.CheckResult, verboseLogger logger.LogInterface) error {
+	sourceVersion, err := db.GetMySQLVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get MySQL version: %w", err)
+	}
+
+	schemaVersion, err := db.GetSystemValue(ctx, "Version")
+	if err != nil {
+		return fmt.Errorf("could not get Mattermost version: %w", err)
+	}
+
+	// the hash is left empty if the applied migrations are not known by this
+	// version of the tool, the postgres command reports the exact differences.
+	hash, err := store.HashMigrations(queries.Assets(), "migrations/postgres", applied)
+	if err != nil {
+		verboseLogger.Printf("could not hash the migrations: %s\n", err)
+	}
+
+	// the sizes are used to check the disk space of the target, which is
+	// skipped if they are not known.
+	tables, err := db.GetTableSizes(ctx, pgloader.ExcludedTables)
+	if err != nil {
+		verboseLogger.Printf("could not get the table sizes: %s\n", err)
+	}
+
+	mysqlConfig := store.DBConfig{
+		AppliedMigrations: applied,
+		SourceVersion:     sourceVersion,
+		SchemaVersion:     schemaVersion,
+		ToolVersion:       Version,
+		CreatedAt:         time.Now().UTC(),
+		MigrationsHash:    hash,
+		Checks:            checks,
+		Tables:            tables,
+	}
+
+	b, err := json.MarshalIndent(mysqlConfig, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not marshal mysql config: %w", err)
+	}
+
+	if err = writeFileAtomically(outputFile, b); err != nil {
+		return fmt.Errorf("could not write to output file: %w", err)
+	}
+
 	return nil
 }
 
-func createProcedures(db *store.DB, baseLogger logger.LogInterface) (func(), error) {
+func runMySQLPreflight(ctx context.Context, db *store.DB, fixes []string, extra []store.TablePrivilege, noProcedures bool, baseLogger logger.LogInterface) error {
+	privileges, err := store.RequiredFixPrivileges(queries.Assets(), fixes...)
+	if err != nil {
+		return err
+	}
+	for _, p := range extra {
+		if !slices.Contains(privileges, p) {
+			privileges = append(privileges, p)
+		}
+	}
+
+	baseLogger.Println("running preflight checks...")
+	results, err := db.RunMySQLPreflight(ctx, store.MySQLPreflightOptions{
+		Fixes:        privileges,
+		NoProcedures: noProcedures,
+	})
+	if err != nil {
+		return fmt.Errorf("could not run preflight checks: %w", err)
+	}
+
+	return reportPreflight(results, baseLogger)
+}
+
+// createProcedures creates the procedures used by the checks and fixes and
+// verifies that all of them exist. If any of them could not be created, the
+// ones already created are dropped and an error is returned. The returned
+// function drops the procedures and should be deferred by the caller so that
+// they are dropped on every exit path.
+func createProcedures(ctx context.Context, db *store.DB, baseLogger logger.LogInterface) (func(), error) {
 	assets := queries.Assets()
 
 	procedures, err := assets.ReadDir("procedures")
@@ -167,95 +396,493 @@ func createProcedures(db *store.DB, baseLogger logger.LogInterface) (func(), err
 		return nil, err
 	}
 
+	cleanUpFn := func() {
+		// the context of the command may already be cancelled at this point,
+		// the pool is used as the connection may be broken by the cancellation.
+		ctx, cancel := context.WithTimeout(context.Background(), procedureCleanupTimeout)
+		defer cancel()
+
+		for _, procedure := range procedures {
+			if !strings.HasPrefix(procedure.Name(), "drop") {
+				continue
+			}
+			b, err2 := assets.ReadFile(filepath.Join("procedures", procedure.Name()))
+			if err2 != nil {
+				baseLogger.Printf("could not read embedded sql file: %s\n", err2)
+				continue
+			}
+			if _, err2 = db.GetDB().ExecContext(ctx, string(b)); err2 != nil {
+				baseLogger.Printf("error during dropping procedures: %s\n", err2)
+			}
+		}
+	}
+
+	var names []string
 	for _, procedure := range procedures {
 		if !strings.HasPrefix(procedure.Name(), "create") {
 			continue
 		}
-		b, err := assets.ReadFile(filepath.Join("procedures", procedure.Name()))
-		if err != nil {
-			baseLogger.Printf("could not read embedded sql file: %s", err)
+		b, err2 := assets.ReadFile(filepath.Join("procedures", procedure.Name()))
+		if err2 != nil {
+			cleanUpFn()
+			return nil, fmt.Errorf("could not read embedded sql file: %w", err2)
 		}
-		err = db.ExecQuery(context.TODO(), string(b))
-		if err != nil {
-			baseLogger.Printf("error during creating procedures: %s", err)
+		for _, m := range createProcedureRegex.FindAllStringSubmatch(string(b), -1) {
+			names = append(names, m[1])
+		}
+
+		if err2 = db.ExecQuery(ctx, string(b)); err2 != nil {
+			cleanUpFn()
+			return nil, fmt.Errorf("could not create procedures from %s: %w", procedure.Name(), err2)
 		}
 	}
 
-	cleanUpFn := func() {
-		for _, procedure := range procedures {
-			if !strings.HasPrefix(procedure.Name(), "drop") {
-				continue
-			}
-			b, err := assets.ReadFile(filepath.Join("procedures", procedure.Name()))
+	missing, err := db.MissingProcedures(ctx, names)
+	if err != nil {
+		cleanUpFn()
+		return nil, err
+	}
+	if len(missing) > 0 {
+		cleanUpFn()
+		return nil, fmt.Errorf("procedures do not exist after creation: %s", strings.Join(missing, ", "))
+	}
+
+	return cleanUpFn, nil
+}
+
+// check is a single check run by a checkRunner.
+type check struct {
+	name string
+	// strategy is recorded in the result if it is not empty.
+	strategy string
+	// note is recorded in the result if it is not empty.
+	note string
+	// count returns the number of rows requiring a fix.
+	count func() (int, error)
+	// describe returns the details of the rows requiring a fix, such as the
+	// keys of a few of them. It is optional.
+	describe func(count int) (string, error)
+	// fix is nil if the rows can not be fixed or the fix is skipped.
+	fix func() error
+}
+
+// checkRunner runs the checks of a category and collects their results. A
+// result is recorded for every check, the failing fixes are recorded in the
+// results so that the remaining checks are still run.
+type checkRunner struct {
+	category      string
+	fix           bool
+	results       []store.CheckResult
+	fixRequired   int
+	baseLogger    logger.LogInterface
+	verboseLogger logger.LogInterface
+}
+
+func newCheckRunner(category string, fix bool, baseLogger, verboseLogger logger.LogInterface) *checkRunner {
+	baseLogger.Printf("running checks for %s...\n", category)
+
+	return &checkRunner{
+		category:      category,
+		fix:           fix,
+		baseLogger:    baseLogger,
+		verboseLogger: verboseLogger,
+	}
+}
+
+func (r *checkRunner) run(c check) error {
+	r.verboseLogger.Printf("checking %s...", c.name)
+	count, err := c.count()
+	if err != nil {
+		return fmt.Errorf("error during running checks: %w", err)
+	}
+
+	r.results = append(r.results, store.CheckResult{
+		Category: r.category,
+		Name:     c.name,
+		Count:    count,
+		Strategy: c.strategy,
+		Note:     c.note,
+	})
+	if count == 0 {
+		r.verboseLogger.Printf("%s is okay", c.name)
+		return nil
+	}
+	r.fixRequired++
+
+	if c.describe != nil {
+		details, err2 := c.describe(count)
+		if err2 != nil {
+			return fmt.Errorf("could not get the rows of %s: %w", c.name, err2)
+		}
+		r.baseLogger.Printf("a fix is required for: %s, %s\n", c.name, details)
+	} else {
+		r.baseLogger.Printf("a fix is required for: %s\n", c.name)
+	}
+
+	if !r.fix {
+		return nil
+	}
+	if c.fix == nil {
+		r.baseLogger.Printf("the fix of %s is skipped.\n", c.name)
+		return nil
+	}
+
+	result := &r.results[len(r.results)-1]
+	if err = c.fix(); err != nil {
+		r.baseLogger.Printf("error while trying to fix %s: %s\n", c.name, err)
+		result.FixError = err.Error()
+		return nil
+	}
+	r.baseLogger.Println("the fix query has been executed successfully.")
+	result.Fixed = true
+	r.fixRequired--
+
+	return nil
+}
+
+// done logs the outcome of the checks and returns their results.
+func (r *checkRunner) done() []store.CheckResult {
+	if r.fixRequired == 0 {
+		r.baseLogger.Printf("%d checks been made, all good for %s\n", len(r.results), r.category)
+	} else {
+		r.baseLogger.Printf("%d checks been made, %d fix(es) is required for %s\n", len(r.results), r.fixRequired, r.category)
+	}
+
+	return r.results
+}
+
+func runChecksForMySQL(ctx context.Context, db *store.DB, checkType string, fix, noProcedures bool, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	return runQueryChecks(ctx, db, checkType, fix, noProcedures, nil, baseLogger, verboseLogger)
+}
+
+// runJSONChecks runs the json checks and reports the primary keys of a few of
+// the rows having invalid values in each of the failing columns.
+func runJSONChecks(ctx context.Context, db *store.DB, fix, noProcedures bool, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	describe := func(query string) func(int) (string, error) {
+		return func(int) (string, error) {
+			keys, err := db.InvalidJSONSamples(ctx, query)
 			if err != nil {
-				baseLogger.Printf("could not read embedded sql file: %s", err)
+				return "", err
 			}
-			err = db.ExecQuery(context.TODO(), string(b))
+			return "rows with invalid JSON: " + strings.Join(keys, "; "), nil
+		}
+	}
+
+	return runQueryChecks(ctx, db, "json", fix, noProcedures, describe, baseLogger, verboseLogger)
+}
+
+// runOrphanChecks runs the orphans checks and reports the primary keys of a few
+// of the rows referencing a missing row for each of the relationships.
+func runOrphanChecks(ctx context.Context, db *store.DB, fix, noProcedures bool, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	describe := func(query string) func(int) (string, error) {
+		return func(int) (string, error) {
+			keys, err := db.OrphanSamples(ctx, query)
 			if err != nil {
-				baseLogger.Printf("error during dropping procedures: %s", err)
+				return "", err
 			}
+			return "orphan rows: " + strings.Join(keys, "; "), nil
 		}
 	}
 
-	return cleanUpFn, nil
+	return runQueryChecks(ctx, db, "orphans", fix, noProcedures, describe, baseLogger, verboseLogger)
 }
 
-func runChecksForMySQL(db *store.DB, checkType string, fix bool, baseLogger, verboseLogger logger.LogInterface) error {
+// runQueryChecks runs the check queries of the category along with their fix
+// queries. If describe is not nil, it returns the describe function of the
+// check of the given query.
+func runQueryChecks(ctx context.Context, db *store.DB, checkType string, fix, noProcedures bool, describe func(query string) func(int) (string, error), baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
 	assets := queries.Assets()
 
 	checks, err := assets.ReadDir(filepath.Join("checks", checkType))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var fixRequired, totalCheck int
-	baseLogger.Printf("running checks for %s...\n", checkType)
+	runner := newCheckRunner(checkType, fix, baseLogger, verboseLogger)
 	for _, artifact := range checks {
 		if !strings.HasPrefix(artifact.Name(), "check") {
 			continue
 		}
-		name := stripQueryName(artifact.Name())
-		b, err := assets.ReadFile(filepath.Join("checks", checkType, artifact.Name()))
-		if err != nil {
-			return fmt.Errorf("could not read embedded sql file: %w", err)
+		b, err2 := assets.ReadFile(filepath.Join("checks", checkType, artifact.Name()))
+		if err2 != nil {
+			return runner.results, fmt.Errorf("could not read embedded sql file: %w", err2)
 		}
-		verboseLogger.Printf("checking %s...", name)
-		count, err := db.RunSelectCountQuery(context.TODO(), string(b))
-		if err != nil {
-			return fmt.Errorf("error during running checks: %w", err)
+		query := string(b)
+
+		c := check{
+			name: stripQueryName(artifact.Name()),
+			count: func() (int, error) {
+				if noProcedures {
+					return db.RunSelectCountQueryWithoutProcedures(ctx, query)
+				}
+				return db.RunSelectCountQuery(ctx, query)
+			},
+			fix: func() error {
+				fixQ, err3 := assets.ReadFile(filepath.Join("fixes", checkType, "fix_"+strings.TrimPrefix(artifact.Name(), "check_")))
+				if err3 != nil {
+					return fmt.Errorf("could not read embedded sql file: %w", err3)
+				}
+				if noProcedures {
+					return db.ExecQueryWithoutProcedures(ctx, string(fixQ))
+				}
+				return db.ExecQuery(ctx, string(fixQ))
+			},
+		}
+		if describe != nil {
+			c.describe = describe(query)
 		}
-		totalCheck++
-		if count == 0 {
-			verboseLogger.Printf("%s is okay", name)
+
+		if err2 = runner.run(c); err2 != nil {
+			return runner.results, err2
+		}
+	}
+
+	return runner.done(), nil
+}
+
+// runEncodingChecks scans the text columns of every table for values that
+// Postgres would reject as invalid UTF-8. A result is reported for each of the
+// scanned columns.
+func runEncodingChecks(ctx context.Context, db *store.DB, fix bool, replacement string, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	all, err := db.GetTextColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// MySQL converts the values of other character sets to valid UTF-8
+	var tables []string
+	byTable := make(map[string][]store.TextColumn)
+	for _, c := range all {
+		if !c.IsUTF8() {
 			continue
 		}
-		fixRequired++
+		if _, ok := byTable[c.Table]; !ok {
+			tables = append(tables, c.Table)
+		}
+		byTable[c.Table] = append(byTable[c.Table], c)
+	}
 
-		baseLogger.Printf("a fix is required for: %s\n", name)
-		if !fix {
+	runner := newCheckRunner("encoding", fix, baseLogger, verboseLogger)
+	for _, table := range tables {
+		verboseLogger.Printf("scanning %s...\n", table)
+		reports, err2 := db.FindInvalidUTF8(ctx, table, byTable[table])
+		if errors.Is(err2, store.ErrNoPrimaryKey) {
+			baseLogger.Printf("skipping %s as it does not have a primary key to identify the rows with\n", table)
 			continue
+		} else if err2 != nil {
+			return runner.results, err2
 		}
 
-		fixQ, err := assets.ReadFile(filepath.Join("fixes", checkType, "fix_"+strings.TrimPrefix(artifact.Name(), "check_")))
+		for _, c := range byTable[table] {
+			r := &store.EncodingReport{Column: c}
+			for _, found := range reports {
+				if found.Column == c {
+					r = found
+				}
+			}
+
+			err2 = runner.run(check{
+				name:  strings.ToLower(c.Table + "." + c.Column),
+				count: func() (int, error) { return r.Count, nil },
+				describe: func(count int) (string, error) {
+					return fmt.Sprintf("%d row(s) are not valid UTF-8 (%s)", count, strings.Join(r.Samples(), "; ")), nil
+				},
+				fix: func() error {
+					_, err3 := db.FixInvalidUTF8(ctx, r, replacement)
+					return err3
+				},
+			})
+			if err2 != nil {
+				return runner.results, err2
+			}
+		}
+	}
+
+	return runner.done(), nil
+}
+
+// runNullCharChecks looks for NUL bytes in the text columns of every table,
+// which Postgres does not allow in text values. A result is reported for each
+// of the checked columns.
+func runNullCharChecks(ctx context.Context, db *store.DB, fix bool, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	columns, err := db.GetTextColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := newCheckRunner("null-chars", fix, baseLogger, verboseLogger)
+	for _, c := range columns {
+		// NUL characters are always escaped in json values
+		if c.DataType == "json" {
+			continue
+		}
+
+		err = runner.run(check{
+			name:  strings.ToLower(c.Table + "." + c.Column),
+			count: func() (int, error) { return db.CountNullBytes(ctx, c) },
+			describe: func(count int) (string, error) {
+				return fmt.Sprintf("%d row(s) contain NUL bytes", count), nil
+			},
+			fix: func() error {
+				_, err2 := db.RemoveNullBytes(ctx, c)
+				return err2
+			},
+		})
 		if err != nil {
-			return fmt.Errorf("could not read embedded sql file: %w", err)
+			return runner.results, err
 		}
+	}
+
+	// the hint is left out if NUL bytes were found, even if they are removed
+	// now, as the server may write them again before the load.
+	results := runner.done()
+	if !slices.ContainsFunc(results, func(r store.CheckResult) bool { return r.Count > 0 }) {
+		baseLogger.Println("no NUL bytes found in the text columns, the pgloader configuration may be generated with --remove-null-chars=false" +
+			" if Mattermost is stopped until the load and the unicode checks pass.")
+	}
 
-		err = db.ExecQuery(context.TODO(), string(fixQ))
+	return results, nil
+}
+
+// runDateChecks looks for zero dates in the date and time columns of the
+// migrated tables, which Postgres rejects. A result is reported for each of
+// the checked columns.
+func runDateChecks(ctx context.Context, db *store.DB, fix bool, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	columns, err := db.GetDateColumns(ctx, pgloader.ExcludedTables)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := newCheckRunner("dates", fix, baseLogger, verboseLogger)
+	for _, c := range columns {
+		err = runner.run(check{
+			name:  strings.ToLower(c.Table + "." + c.Column),
+			count: func() (int, error) { return db.CountZeroDates(ctx, c) },
+			describe: func(count int) (string, error) {
+				samples, err2 := db.ZeroDateSamples(ctx, c)
+				if err2 != nil {
+					return "", err2
+				}
+				return fmt.Sprintf("%d row(s) contain zero dates (%s)", count, strings.Join(samples, "; ")), nil
+			},
+			fix: func() error {
+				_, err2 := db.FixZeroDates(ctx, c)
+				return err2
+			},
+		})
 		if err != nil {
-			return fmt.Errorf("error while trying to fix %s error: %w", name, err)
+			return runner.results, err
 		}
-		baseLogger.Println("the fix query has been executed successfully.")
-		fixRequired--
 	}
 
-	if fixRequired == 0 {
-		baseLogger.Printf("%d checks been made, all good for %s\n", totalCheck, checkType)
+	return runner.done(), nil
+}
+
+// getVarcharColumns returns the MySQL columns which may hold values longer
+// than the varchar limits of the Postgres schema. The limits are read from the
+// given migrations directory, or from the embedded migrations if it is empty,
+// following the applied migrations only.
+func getVarcharColumns(ctx context.Context, db *store.DB, migrationsDir string, applied []int) ([]store.VarcharColumn, error) {
+	var limits []store.VarcharLimit
+	var err error
+	if migrationsDir != "" {
+		limits, err = store.VarcharLimits(os.DirFS(migrationsDir), ".", applied)
 	} else {
-		baseLogger.Printf("%d checks been made, %d fix(es) is required for %s\n", totalCheck, fixRequired, checkType)
+		limits, err = store.VarcharLimits(queries.Assets(), "migrations/postgres", applied)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the varchar limits: %w", err)
 	}
 
-	return nil
+	columns, err := db.GetVarcharColumns(ctx, limits)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the varchar columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// runVarcharChecks looks for values longer than the varchar limits of the
+// Postgres schema, which would fail the load. A result is reported for each of
+// the checked columns, along with the strategy to fix them with.
+func runVarcharChecks(ctx context.Context, db *store.DB, columns []store.VarcharColumn, fix bool, strategies store.VarcharStrategies, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	runner := newCheckRunner("varchar", fix, baseLogger, verboseLogger)
+	for _, c := range columns {
+		strategy := strategies.For(c)
+		vc := check{
+			name:     strings.ToLower(c.Table + "." + c.Column),
+			strategy: string(strategy),
+			count:    func() (int, error) { return db.CountVarcharOverflow(ctx, c) },
+			describe: func(count int) (string, error) {
+				return fmt.Sprintf("%d row(s) are longer than %d characters", count, c.Length), nil
+			},
+		}
+		if strategy != store.VarcharSkip {
+			vc.fix = func() error {
+				_, err := db.FixVarcharOverflow(ctx, c, strategy)
+				return err
+			}
+		}
+
+		if err := runner.run(vc); err != nil {
+			return runner.results, err
+		}
+	}
+
+	return runner.done(), nil
+}
+
+// runCollationChecks reports the unique indexes whose uniqueness changes under
+// the byte-wise comparison of Postgres. A result is reported for each of the
+// checked indexes, and another one for the rows of the case or accent
+// insensitive indexes which Postgres compares differently. These can not be
+// fixed automatically.
+func runCollationChecks(ctx context.Context, db *store.DB, baseLogger, verboseLogger logger.LogInterface) ([]store.CheckResult, error) {
+	indexes, err := db.GetUniqueTextIndexes(ctx, pgloader.ExcludedTables)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := newCheckRunner("collations", false, baseLogger, verboseLogger)
+	for _, idx := range indexes {
+		name := strings.ToLower(idx.Table + "." + idx.Name)
+		err = runner.run(check{
+			name:  name,
+			count: func() (int, error) { return db.CountUniqueCollisions(ctx, idx) },
+			describe: func(count int) (string, error) {
+				samples, err2 := db.UniqueCollisionSamples(ctx, idx)
+				if err2 != nil {
+					return "", err2
+				}
+				return fmt.Sprintf("%d row(s) would violate the index once the NUL characters are removed (%s)", count, strings.Join(samples, "; ")), nil
+			},
+		})
+		if err != nil {
+			return runner.results, err
+		}
+
+		columns := idx.CaseInsensitiveColumns()
+		if len(columns) == 0 {
+			continue
+		}
+		err = runner.run(check{
+			name:  name + " case",
+			note:  fmt.Sprintf("case insensitive on %s", strings.Join(columns, ", ")),
+			count: func() (int, error) { return db.CountCaseSensitiveRows(ctx, idx) },
+			describe: func(count int) (string, error) {
+				samples, err2 := db.CaseSensitiveSamples(ctx, idx)
+				if err2 != nil {
+					return "", err2
+				}
+				return fmt.Sprintf("%d row(s) only match case and accent sensitively in Postgres (%s)", count, strings.Join(samples, "; ")), nil
+			},
+		})
+		if err != nil {
+			return runner.results, err
+		}
+	}
+
+	return runner.done(), nil
 }
 
 func stripQueryName(fileName string) string {
@@ -264,7 +891,24 @@ func stripQueryName(fileName string) string {
 	return strings.TrimSuffix(fileName, ".sql")
 }
 
-func runFullSchemaCheck(db *store.DB, migrationsDir, tempDir string, v semver.Version, baseLogger, verboseLogger logger.LogInterface, saveDiff bool) error {
+// detectMattermostVersion infers the Mattermost version of the MySQL database
+// by using the release table embedded into the tool.
+func detectMattermostVersion(ctx context.Context, db *store.DB, baseLogger logger.LogInterface) (semver.Version, error) {
+	releases, err := store.LoadReleases(queries.Assets(), "releases.json")
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	v, err := db.DetectMattermostVersion(ctx, releases, baseLogger)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("could not detect Mattermost version, please supply --mattermost-version: %w", err)
+	}
+	baseLogger.Printf("detected Mattermost version: %s\n", v.String())
+
+	return v, nil
+}
+
+func runFullSchemaCheck(db *store.DB, migrationsDir string, v semver.Version, baseLogger, verboseLogger logger.LogInterface, saveDiff bool) error {
 	ctx := context.Background()
 
 	var mysqlContainer *module.MySQLContainer
@@ -294,20 +938,12 @@ func runFullSchemaCheck(db *store.DB, migrationsDir, tempDir string, v semver.Ve
 		log.Fatalf("failed to get connection string of container: %s", err)
 	}
 
-	dir := "mysql"
-	if migrationsDir == "" {
-		baseLogger.Printf("cloning %s@%s\n", "repository", v.String())
-		err = git.CloneMigrations(git.CloneOptions{
-			TempRepoPath: tempDir,
-			Output:       dir,
-			DriverType:   "mysql",
-			Version:      v,
-		}, verboseLogger)
+	dir := migrationsDir
+	if dir == "" {
+		dir, err = cachedMigrations(v, "mysql", baseLogger, verboseLogger)
 		if err != nil {
-			return fmt.Errorf("error during cloning migrations: %w", err)
+			return err
 		}
-	} else {
-		dir = migrationsDir
 	}
 
 	// create mysql connection
@@ -339,16 +975,11 @@ func runFullSchemaCheck(db *store.DB, migrationsDir, tempDir string, v semver.Ve
 	return nil
 }
 
-func checkMySQLDBVersion(db *store.DB, baseLogger logger.LogInterface, fixUnicode bool) error {
-	row := db.GetDB().QueryRow(`SELECT VERSION()`)
-	if err := row.Err(); err != nil {
+func checkMySQLDBVersion(ctx context.Context, db *store.DB, baseLogger logger.LogInterface, fixUnicode bool) error {
+	version, err := db.GetMySQLVersion(ctx)
+	if err != nil {
 		return fmt.Errorf("could not get MySQL version: %w", err)
 	}
-
-	var version string
-	if err := row.Scan(&version); err != nil {
-		return fmt.Errorf("could not scan MySQL version: %w", err)
-	}
 	baseLogger.Printf("MySQL version: %s\n", version)
 
 	extractedMajorVersion := strings.Split(version, ".")[0]
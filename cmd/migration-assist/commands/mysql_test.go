@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattermost/migration-assist/internal/logger"
+	"github.com/mattermost/migration-assist/internal/store"
+)
+
+func TestCheckRunner(t *testing.T) {
+	var fixed []string
+	fixFn := func(name string, err error) func() error {
+		return func() error {
+			fixed = append(fixed, name)
+			return err
+		}
+	}
+	countFn := func(n int) func() (int, error) {
+		return func() (int, error) { return n, nil }
+	}
+
+	runner := newCheckRunner("test", true, logger.NewNopLogger(), logger.NewNopLogger())
+	checks := []check{
+		{name: "ok", count: countFn(0), fix: fixFn("ok", nil)},
+		{name: "fixed", count: countFn(2), fix: fixFn("fixed", nil)},
+		{name: "failed", count: countFn(1), fix: fixFn("failed", errors.New("boom"))},
+		{name: "skipped", strategy: "skip", count: countFn(3)},
+	}
+	for _, c := range checks {
+		if err := runner.run(c); err != nil {
+			t.Fatalf("run(%s) error = %v, want no error", c.name, err)
+		}
+	}
+
+	if err := runner.run(check{name: "error", count: func() (int, error) { return 0, errors.New("boom") }}); err == nil {
+		t.Errorf("run() error = nil, want an error")
+	}
+
+	want := []store.CheckResult{
+		{Category: "test", Name: "ok"},
+		{Category: "test", Name: "fixed", Count: 2, Fixed: true},
+		{Category: "test", Name: "failed", Count: 1, FixError: "boom"},
+		{Category: "test", Name: "skipped", Count: 3, Strategy: "skip"},
+	}
+	got := runner.done()
+	if len(got) != len(want) {
+		t.Fatalf("done() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if len(fixed) != 2 || fixed[0] != "fixed" || fixed[1] != "failed" {
+		t.Errorf("fixed = %v, want [fixed failed]", fixed)
+	}
+	if runner.fixRequired != 2 {
+		t.Errorf("fixRequired = %d, want 2", runner.fixRequired)
+	}
+}
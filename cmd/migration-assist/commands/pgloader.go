@@ -30,6 +30,7 @@ func GeneratePgloaderConfigCmd() *cobra.Command {
 	// Optional flags
 	cmd.PersistentFlags().String("output", "", "The filename of the generated configuration")
 	cmd.PersistentFlags().Bool("remove-null-chars", true, "Adds transformations to remove null characters on the fly")
+	cmd.PersistentFlags().String("schema", defaultSchema, "The target schema for the Mattermost tables")
 	return cmd
 }
 
@@ -73,10 +74,12 @@ func genPgloaderCmdFn(product string) func(cmd *cobra.Command, _ []string) error
 
 		output, _ := cmd.Flags().GetString("output")
 		removeNull, _ := cmd.Flags().GetBool("remove-null-chars")
+		schema, _ := cmd.Flags().GetString("schema")
 		baseLogger := logger.NewLogger(os.Stderr, logger.Options{Timestamps: true})
 		err := pgloader.GenerateConfigurationFile(output, product, pgloader.PgLoaderConfig{
 			MySQLDSN:             mysqlDSN,
 			PostgresDSN:          postgresDSN,
+			Schema:               schema,
 			RemoveNullCharacters: removeNull,
 		}, baseLogger)
 		if err != nil {
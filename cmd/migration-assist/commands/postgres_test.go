@@ -0,0 +1,29 @@
+package commands
+
+import "testing"
+
+func TestIsLocalAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{address: "localhost:5432", want: true},
+		{address: "127.0.0.1:5432", want: true},
+		{address: "[::1]:5432", want: true},
+		{address: "localhost", want: true},
+		{address: ":5432", want: true},
+		{address: "/var/run/postgresql", want: true},
+		{address: "/var/run/postgresql:5432", want: true},
+		{address: "192.168.1.100:5432", want: false},
+		{address: "db.example.com:5432", want: false},
+		{address: "[2001:db8::1]:5432", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := isLocalAddress(tt.address); got != tt.want {
+				t.Errorf("isLocalAddress(%q) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
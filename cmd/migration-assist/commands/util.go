@@ -4,8 +4,31 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/migration-assist/internal/logger"
+	"github.com/mattermost/migration-assist/internal/store"
 )
 
+// Confirm asks the question unless it is answered by the --yes or --no-input
+// flags. The question is declined without waiting for input if stdin is not a
+// terminal, so that the commands do not block when run unattended.
+func Confirm(cmd *cobra.Command, question string) bool {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		fmt.Fprintf(os.Stderr, "%s [y/N]: y (--yes)\n", question)
+		return true
+	}
+
+	if noInput, _ := cmd.Flags().GetBool("no-input"); noInput || !isTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "%s [y/N]: N (non-interactive)\n", question)
+		return false
+	}
+
+	return ConfirmationPrompt(question)
+}
+
 func ConfirmationPrompt(question string) bool {
 	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
 
@@ -22,3 +45,56 @@ func ConfirmationPrompt(question string) bool {
 
 	return false
 }
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// writeFileAtomically writes the data to a temporary file next to the named
+// file and renames it, so that an existing file is only replaced once the data
+// is written completely.
+func writeFileAtomically(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// reportPreflight prints the results of the preflight checks and returns an
+// error if any of them failed.
+func reportPreflight(results []store.PreflightResult, baseLogger logger.LogInterface) error {
+	var failed int
+	for _, res := range results {
+		baseLogger.Printf("[%s] %s: %s\n", res.Status, res.Name, res.Message)
+		if res.Status == store.PreflightFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d preflight check(s) failed", failed)
+	}
+	baseLogger.Println("preflight checks passed.")
+
+	return nil
+}
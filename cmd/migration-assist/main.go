@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -20,16 +24,36 @@ var root = &cobra.Command{
 
 func main() {
 	root.PersistentFlags().Bool("verbose", false, "Becomes verbose")
+	root.PersistentFlags().Bool("yes", false, "Answers yes to all of the questions")
+	root.PersistentFlags().Bool("no-input", false, "Never asks for input, the questions are answered with no")
+	root.MarkFlagsMutuallyExclusive("yes", "no-input")
 
 	root.AddCommand(
 		commands.SourceCheckCmd(),
 		commands.TargetCheckCmd(),
 		commands.GeneratePgloaderConfigCmd(),
+		commands.EstimateCmd(),
+		commands.CacheCmd(),
 		commands.VersionCmd(),
 	)
 
-	if err := root.Execute(); err != nil {
+	// cancel the context on interrupt so that the commands can clean up, a
+	// second signal terminates the process immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := root.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "An Error Occurred: %s\n", err.Error())
+
+		var exitErr *commands.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,324 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+const (
+	applicationDir   = "migration-assist"
+	manifestSuffix   = ".manifest.json"
+	manifestFileMode = 0644
+)
+
+var (
+	// ErrNotCached is returned when there is no cached migration set for the
+	// requested version and driver.
+	ErrNotCached = errors.New("migrations are not cached")
+)
+
+// Cache is a local store of the migration sets cloned from the Mattermost
+// repository. Each set lives under <root>/<version>/<driver> and is accompanied
+// by a manifest at <root>/<version>/<driver>.manifest.json which records the
+// checksums of the files so that a tampered or partially written set can be
+// detected before it is used.
+type Cache struct {
+	root string
+}
+
+type Manifest struct {
+	Version   string            `json:"version"`
+	Driver    string            `json:"driver"`
+	CreatedAt time.Time         `json:"created_at"`
+	Digest    string            `json:"digest"`
+	Files     map[string]string `json:"files"`
+}
+
+type Entry struct {
+	Version   semver.Version
+	Driver    string
+	Path      string
+	Digest    string
+	Files     int
+	Size      int64
+	CreatedAt time.Time
+	// ModTime is the modification time of the directory, e.g. to tell the age
+	// of an entry without a manifest.
+	ModTime time.Time
+	// Err is set if the entry could not be verified.
+	Err error
+}
+
+// DefaultDir returns the default cache directory, which is
+// $XDG_CACHE_HOME/migration-assist on Linux.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, applicationDir), nil
+}
+
+func New(root string) *Cache {
+	return &Cache{root: root}
+}
+
+func (c *Cache) Root() string {
+	return c.root
+}
+
+// Path returns the directory that holds the migrations of the given version
+// and driver, regardless of whether it exists or not.
+func (c *Cache) Path(v semver.Version, driver string) string {
+	return filepath.Join(c.root, versionKey(v), driver)
+}
+
+func (c *Cache) manifestPath(v semver.Version, driver string) string {
+	return filepath.Join(c.root, versionKey(v), driver+manifestSuffix)
+}
+
+// Verify checks the cached files of the given version and driver against the
+// checksums recorded in the manifest. ErrNotCached is returned if either the
+// manifest or the migrations directory is missing.
+func (c *Cache) Verify(v semver.Version, driver string) error {
+	b, err := os.ReadFile(c.manifestPath(v, driver))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotCached
+	} else if err != nil {
+		return fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("could not decode manifest: %w", err)
+	}
+
+	return verifyManifest(c.Path(v, driver), &m)
+}
+
+// Seal computes the checksums of the migration files of the given version and
+// driver and writes the manifest. It should be called once the files are in place.
+func (c *Cache) Seal(v semver.Version, driver string) (*Manifest, error) {
+	files, err := checksums(c.Path(v, driver))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:   versionKey(v),
+		Driver:    driver,
+		CreatedAt: time.Now().UTC(),
+		Digest:    digest(files),
+		Files:     files,
+	}
+
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal manifest: %w", err)
+	}
+
+	if err = os.WriteFile(c.manifestPath(v, driver), b, manifestFileMode); err != nil {
+		return nil, fmt.Errorf("could not write manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// Remove deletes the cached migrations of the given version and driver along
+// with its manifest.
+func (c *Cache) Remove(v semver.Version, driver string) error {
+	if err := os.Remove(c.manifestPath(v, driver)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove manifest: %w", err)
+	}
+
+	if err := os.RemoveAll(c.Path(v, driver)); err != nil {
+		return fmt.Errorf("could not remove cached migrations: %w", err)
+	}
+
+	// remove the version directory if it is empty, errors are ignored on purpose
+	_ = os.Remove(filepath.Dir(c.Path(v, driver)))
+
+	return nil
+}
+
+// List returns the cached migration sets sorted by version and driver. Every
+// entry is verified, a failed verification is reported through Entry.Err.
+// Directories which are not named after a version are skipped.
+func (c *Cache) List() ([]Entry, error) {
+	versions, err := os.ReadDir(c.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read cache directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, vd := range versions {
+		if !vd.IsDir() {
+			continue
+		}
+		v, err2 := semver.ParseTolerant(vd.Name())
+		if err2 != nil {
+			continue
+		}
+
+		drivers, err2 := os.ReadDir(filepath.Join(c.root, vd.Name()))
+		if err2 != nil {
+			return nil, fmt.Errorf("could not read cache directory: %w", err2)
+		}
+
+		for _, dd := range drivers {
+			if !dd.IsDir() {
+				continue
+			}
+			entries = append(entries, c.entry(v, dd.Name()))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Version.EQ(entries[j].Version) {
+			return entries[i].Driver < entries[j].Driver
+		}
+		return entries[i].Version.LT(entries[j].Version)
+	})
+
+	return entries, nil
+}
+
+func (c *Cache) entry(v semver.Version, driver string) Entry {
+	e := Entry{
+		Version: v,
+		Driver:  driver,
+		Path:    c.Path(v, driver),
+	}
+	if info, err := os.Stat(e.Path); err == nil {
+		e.ModTime = info.ModTime()
+	}
+
+	b, err := os.ReadFile(c.manifestPath(v, driver))
+	if err != nil {
+		e.Err = ErrNotCached
+		return e
+	}
+
+	var m Manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		e.Err = fmt.Errorf("could not decode manifest: %w", err)
+		return e
+	}
+
+	e.Digest = m.Digest
+	e.Files = len(m.Files)
+	e.CreatedAt = m.CreatedAt
+	e.Err = verifyManifest(e.Path, &m)
+
+	_ = filepath.WalkDir(e.Path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err2 := d.Info(); err2 == nil {
+			e.Size += info.Size()
+		}
+		return nil
+	})
+
+	return e
+}
+
+func verifyManifest(dir string, m *Manifest) error {
+	files, err := checksums(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotCached
+	} else if err != nil {
+		return err
+	}
+
+	if len(files) != len(m.Files) {
+		return fmt.Errorf("expected %d files in %s, found %d", len(m.Files), dir, len(files))
+	}
+
+	for name, sum := range m.Files {
+		actual, ok := files[name]
+		if !ok {
+			return fmt.Errorf("file %s is missing from the cache", name)
+		}
+		if actual != sum {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+
+	if digest(files) != m.Digest {
+		return fmt.Errorf("digest mismatch for %s", dir)
+	}
+
+	return nil
+}
+
+func checksums(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err != nil {
+			return fmt.Errorf("could not read %s: %w", rel, err)
+		}
+		files[filepath.ToSlash(rel)] = hex.EncodeToString(h.Sum(nil))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// digest derives a single checksum from the file checksums, so that the whole
+// migration set can be addressed by its content.
+func digest(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s  %s\n", files[name], name)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func versionKey(v semver.Version) string {
+	return "v" + v.String()
+}
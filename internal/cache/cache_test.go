@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/blang/semver/v4"
+)
+
+func TestCache(t *testing.T) {
+	c := New(t.TempDir())
+	v := semver.MustParse("9.11.0")
+
+	if err := c.Verify(v, "postgres"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrNotCached)
+	}
+
+	dir := c.Path(v, "postgres")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "000001_create_teams.up.sql"), []byte("CREATE TABLE teams();"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := c.Seal(v, "postgres")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if len(m.Files) != 1 {
+		t.Errorf("Seal() recorded %d files, want 1", len(m.Files))
+	}
+
+	if err = c.Verify(v, "postgres"); err != nil {
+		t.Errorf("Verify() error = %v, want no error", err)
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || !entries[0].Version.EQ(v) || entries[0].Driver != "postgres" || entries[0].Err != nil {
+		t.Errorf("List() = %+v, want a single valid v9.11.0/postgres entry", entries)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "000001_create_teams.up.sql"), []byte("DROP TABLE teams;"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Verify(v, "postgres"); err == nil {
+		t.Errorf("Verify() expected checksum error but got nil")
+	}
+
+	if err = c.Remove(v, "postgres"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err = c.Verify(v, "postgres"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrNotCached)
+	}
+}
+
+func TestListSortsVersions(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"v10.0.0/postgres", "v9.11.0/postgres", "v9.11.0/mysql", "latest/postgres"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := New(root).List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, "v"+e.Version.String()+"/"+e.Driver)
+		if !errors.Is(e.Err, ErrNotCached) || e.ModTime.IsZero() {
+			t.Errorf("List() entry %s/%s = %+v, want an entry without a manifest aged by its directory", e.Version, e.Driver, e)
+		}
+	}
+	if want := []string{"v9.11.0/mysql", "v9.11.0/postgres", "v10.0.0/postgres"}; !slices.Equal(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
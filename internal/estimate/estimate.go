@@ -0,0 +1,161 @@
+// Package estimate provides a rough model of the duration and the disk usage
+// of a migration from the sizes of the MySQL tables.
+package estimate
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattermost/migration-assist/internal/pgloader"
+	"github.com/mattermost/migration-assist/internal/store"
+)
+
+const (
+	// defaultWorkers and defaultBatchRows are used by pgLoader if the
+	// template does not set them.
+	defaultWorkers   = 4
+	defaultBatchRows = 25000
+
+	// postgresOverhead is the ratio of the size of the loaded tables in
+	// Postgres to their size in MySQL, mostly due to the tuple headers and
+	// the binary representation of the jsonb columns.
+	postgresOverhead = 1.3
+	// fulltextIndexRatio is the ratio of the size of a full text index to the
+	// size of the indexed table.
+	fulltextIndexRatio = 0.5
+	// spaceMargin is added to the required space for the WAL and the
+	// statistics of the tables.
+	spaceMargin = 0.1
+)
+
+// Model is the throughput assumed for the migration. The defaults are
+// conservative, they can be calibrated with the results of a test migration.
+type Model struct {
+	// RowsPerSecond and BytesPerSecond are loaded by each pgLoader worker,
+	// the lower of the two limits the load of a table.
+	RowsPerSecond  int64
+	BytesPerSecond int64
+	// BatchOverhead is spent on each batch committed by pgLoader.
+	BatchOverhead time.Duration
+	// IndexBytesPerSecond are indexed while creating the full text indexes.
+	IndexBytesPerSecond int64
+}
+
+// DefaultModel returns the model used if none of the rates are supplied.
+func DefaultModel() Model {
+	return Model{
+		RowsPerSecond:       10000,
+		BytesPerSecond:      10 << 20,
+		BatchOverhead:       10 * time.Millisecond,
+		IndexBytesPerSecond: 20 << 20,
+	}
+}
+
+// Table is the estimate of a single table.
+type Table struct {
+	store.TableSize
+	// Product is the pgLoader configuration template loading the table.
+	Product string
+	Load    time.Duration
+	Index   time.Duration
+	// TargetSize includes the full text indexes created on the table.
+	TargetSize int64
+}
+
+// Estimate is the estimate of the whole migration.
+type Estimate struct {
+	Tables []Table
+	// Load is the duration of the pgLoader runs, which are run one after
+	// the other for each of the products.
+	Load time.Duration
+	// Index is the duration of the index creation of post-migrate.
+	Index      time.Duration
+	TargetSize int64
+}
+
+// Downtime is the total duration of the migration.
+func (e Estimate) Downtime() time.Duration {
+	return e.Load + e.Index
+}
+
+// RequiredSpace is the disk space required by the migration. Besides the size
+// of the tables, the largest full text index requires temporary files of the
+// same size while it is created.
+func (e Estimate) RequiredSpace() int64 {
+	var largestIndex int64
+	for _, t := range e.Tables {
+		if t.Index > 0 {
+			largestIndex = max(largestIndex, int64(float64(t.DataLength)*fulltextIndexRatio))
+		}
+	}
+
+	return int64(float64(e.TargetSize+largestIndex) * (1 + spaceMargin))
+}
+
+// Estimate returns the estimate of the migration of the tables with the
+// settings of the configuration templates, keyed by product. The tables which
+// are not loaded by any of the templates are skipped. The full text indexes
+// are the tables indexed by post-migrate.
+func (m Model) Estimate(tables []store.TableSize, settings map[string]pgloader.Settings, fulltextIndexes []string) Estimate {
+	var e Estimate
+	products := make(map[string][]time.Duration)
+	for _, size := range tables {
+		product, ok := pgloader.TemplateProduct(size.Table)
+		if !ok {
+			continue
+		}
+
+		t := Table{
+			TableSize:  size,
+			Product:    product,
+			Load:       m.load(size, settings[product]),
+			TargetSize: int64(float64(size.DataLength+size.IndexLength) * postgresOverhead),
+		}
+		if slices.ContainsFunc(fulltextIndexes, func(table string) bool { return strings.EqualFold(table, size.Table) }) {
+			t.Index = rate(size.DataLength, m.IndexBytesPerSecond)
+			t.TargetSize += int64(float64(size.DataLength) * fulltextIndexRatio)
+		}
+
+		products[product] = append(products[product], t.Load)
+		e.Tables = append(e.Tables, t)
+		e.Index += t.Index
+		e.TargetSize += t.TargetSize
+	}
+
+	for product, loads := range products {
+		workers := settings[product].Workers
+		if workers == 0 {
+			workers = defaultWorkers
+		}
+
+		// the workers share the tables, but a table is written by a single
+		// worker so the largest table may take longer than the rest.
+		var total time.Duration
+		for _, l := range loads {
+			total += l
+		}
+		e.Load += max(total/time.Duration(workers), slices.Max(loads))
+	}
+
+	return e
+}
+
+// load returns the time a single worker takes to load the table.
+func (m Model) load(size store.TableSize, settings pgloader.Settings) time.Duration {
+	batchRows := int64(settings.BatchRows)
+	if batchRows == 0 {
+		batchRows = defaultBatchRows
+	}
+	batches := (size.Rows + batchRows - 1) / batchRows
+
+	return max(rate(size.Rows, m.RowsPerSecond), rate(size.DataLength, m.BytesPerSecond)) + time.Duration(batches)*m.BatchOverhead
+}
+
+func rate(n, perSecond int64) time.Duration {
+	if perSecond <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(n) / float64(perSecond) * float64(time.Second))
+}
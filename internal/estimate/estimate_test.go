@@ -0,0 +1,64 @@
+package estimate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/migration-assist/internal/pgloader"
+	"github.com/mattermost/migration-assist/internal/store"
+)
+
+func TestEstimate(t *testing.T) {
+	model := Model{
+		RowsPerSecond:       1000,
+		BytesPerSecond:      1 << 20,
+		BatchOverhead:       time.Second,
+		IndexBytesPerSecond: 1 << 20,
+	}
+	settings := map[string]pgloader.Settings{
+		"":       {Workers: 2, BatchRows: 1000},
+		"boards": {Workers: 4},
+	}
+	tables := []store.TableSize{
+		// 10 batches, 10s for the rows, 20s for the data
+		{Table: "Posts", Rows: 10000, DataLength: 20 << 20, IndexLength: 10 << 20},
+		// 2 batches, 2s for the rows, 1s for the data
+		{Table: "Users", Rows: 2000, DataLength: 1 << 20},
+		// 1 batch, 1s for the rows
+		{Table: "Teams", Rows: 1000},
+		// 2 batches of the default size, 30s for the rows
+		{Table: "focalboard_blocks", Rows: 30000},
+		{Table: "db_migrations", Rows: 500, DataLength: 1 << 20},
+	}
+
+	e := model.Estimate(tables, settings, []string{"posts"})
+
+	if len(e.Tables) != 4 {
+		t.Fatalf("Estimate() returned %d tables, want 4", len(e.Tables))
+	}
+	if got, want := e.Tables[0].Load, 30*time.Second; got != want {
+		t.Errorf("Posts load = %s, want %s", got, want)
+	}
+
+	// the largest table of Mattermost takes longer than the share of the
+	// workers, (30s + 4s + 2s) / 2 = 18s
+	if got, want := e.Load, 30*time.Second+32*time.Second; got != want {
+		t.Errorf("Load = %s, want %s", got, want)
+	}
+	if got, want := e.Index, 20*time.Second; got != want {
+		t.Errorf("Index = %s, want %s", got, want)
+	}
+	if got, want := e.Downtime(), 82*time.Second; got != want {
+		t.Errorf("Downtime() = %s, want %s", got, want)
+	}
+
+	// 30MiB * 1.3 + 20MiB * 0.5 + 1MiB * 1.3
+	if got, want := e.TargetSize, int64(51380224+1363148); got != want {
+		t.Errorf("TargetSize = %d, want %d", got, want)
+	}
+
+	// (52743372 + 10MiB for building the index of Posts) * 1.1
+	if got, want := e.RequiredSpace(), int64(69552045); got != want {
+		t.Errorf("RequiredSpace() = %d, want %d", got, want)
+	}
+}
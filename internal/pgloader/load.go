@@ -6,9 +6,14 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/mattermost/migration-assist/internal/logger"
 	"github.com/mattermost/migration-assist/internal/store"
 )
@@ -16,6 +21,17 @@ import (
 //go:embed templates
 var assets embed.FS
 
+// ExcludedTables are excluded by name from the configuration template of
+// Mattermost, the checks and the estimates skip them as well.
+var ExcludedTables = []string{"schema_migrations", "db_migrations", "db_lock", "configurations", "configurationfiles", "db_config_migrations"}
+
+// templateFuncs are available to the configuration templates.
+var templateFuncs = template.FuncMap{
+	"quoteIdentifier": pq.QuoteIdentifier,
+}
+
+var settingRegex = regexp.MustCompile(`(workers|concurrency|rows per range|prefetch rows|batch rows)\s*=\s*(\d+)`)
+
 type Parameters struct {
 	MySQLUser     string
 	MySQLPassword string
@@ -26,42 +42,50 @@ type Parameters struct {
 	PGPassword   string
 	PGAddress    string
 	TargetSchema string
+	PGSchema     string
 
 	RemoveNullCharacters bool
 	SearchPath           string
+	ExcludedTables       []string
 }
 
 type PgLoaderConfig struct {
 	MySQLDSN    string
 	PostgresDSN string
+	// Schema is the Postgres schema the Mattermost tables are created in.
+	Schema string
 
 	RemoveNullCharacters bool
 }
 
+// Settings are the load options defined in a configuration template.
+type Settings struct {
+	Workers      int
+	Concurrency  int
+	RowsPerRange int
+	PrefetchRows int
+	BatchRows    int
+}
+
 func GenerateConfigurationFile(output, product string, config PgLoaderConfig, baseLogger logger.LogInterface) error {
-	var f string
-	switch product {
-	case "boards":
-		f = "boards"
-	case "playbooks":
-		f = "playbooks"
-	case "calls":
-		f = "calls"
-	default:
-		f = "config"
-	}
-	bytes, err := assets.ReadFile(fmt.Sprintf("templates/%s.tmpl", f))
+	bytes, err := readTemplate(product)
 	if err != nil {
-		return fmt.Errorf("could not read configuration template: %w", err)
+		return err
 	}
 
-	templ, err := template.New("cfg").Parse(string(bytes))
+	if config.Schema == "" {
+		return fmt.Errorf("no target schema is supplied")
+	}
+
+	templ, err := template.New("cfg").Funcs(templateFuncs).Parse(string(bytes))
 	if err != nil {
 		return fmt.Errorf("could not parse template: %w", err)
 	}
 
 	params := Parameters{
+		PGSchema:             config.Schema,
 		RemoveNullCharacters: config.RemoveNullCharacters,
+		ExcludedTables:       ExcludedTables,
 	}
 	err = parseMySQL(&params, config.MySQLDSN)
 	if err != nil {
@@ -95,6 +119,11 @@ func GenerateConfigurationFile(output, product string, config PgLoaderConfig, ba
 		return fmt.Errorf("could not query scan search path: %w", err)
 	}
 
+	if !slices.Contains(store.ParseSearchPath(params.SearchPath), params.PGSchema) {
+		baseLogger.Printf("schema %q is not in the search_path, it will be set to %q after the load\n", params.PGSchema, params.PGSchema)
+		params.SearchPath = pq.QuoteIdentifier(params.PGSchema)
+	}
+
 	var writer io.Writer
 	switch output {
 	case "":
@@ -116,6 +145,75 @@ func GenerateConfigurationFile(output, product string, config PgLoaderConfig, ba
 	return nil
 }
 
+// TemplateSettings returns the load options of the configuration template of
+// the given product. The options not set in the template are left as zero.
+func TemplateSettings(product string) (Settings, error) {
+	b, err := readTemplate(product)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	var settings Settings
+	for _, m := range settingRegex.FindAllStringSubmatch(string(b), -1) {
+		n, err2 := strconv.Atoi(m[2])
+		if err2 != nil {
+			return Settings{}, fmt.Errorf("could not parse %s: %w", m[1], err2)
+		}
+
+		switch m[1] {
+		case "workers":
+			settings.Workers = n
+		case "concurrency":
+			settings.Concurrency = n
+		case "rows per range":
+			settings.RowsPerRange = n
+		case "prefetch rows":
+			settings.PrefetchRows = n
+		case "batch rows":
+			settings.BatchRows = n
+		}
+	}
+
+	return settings, nil
+}
+
+// TemplateProduct returns the product whose configuration template loads the
+// table. The second value is false if none of the templates load it.
+func TemplateProduct(table string) (string, bool) {
+	switch {
+	case strings.Contains(table, "IR_"):
+		return "playbooks", true
+	case strings.Contains(table, "focalboard"):
+		return "boards", true
+	case strings.Contains(table, "calls"):
+		return "calls", true
+	case slices.Contains(ExcludedTables, table):
+		return "", false
+	default:
+		return "", true
+	}
+}
+
+func readTemplate(product string) ([]byte, error) {
+	var f string
+	switch product {
+	case "boards":
+		f = "boards"
+	case "playbooks":
+		f = "playbooks"
+	case "calls":
+		f = "calls"
+	default:
+		f = "config"
+	}
+	b, err := assets.ReadFile(fmt.Sprintf("templates/%s.tmpl", f))
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration template: %w", err)
+	}
+
+	return b, nil
+}
+
 func parseMySQL(params *Parameters, dsn string) error {
 	cfg, err := mysql.ParseDSN(dsn)
 	if err != nil {
@@ -1,7 +1,9 @@
 package pgloader
 
 import (
+	"strings"
 	"testing"
+	"text/template"
 )
 
 func TestParseMySQL(t *testing.T) {
@@ -316,3 +318,89 @@ func TestParsePostgres(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateSettings(t *testing.T) {
+	tests := []struct {
+		product string
+		want    Settings
+	}{
+		{
+			product: "",
+			want: Settings{
+				Workers:      8,
+				Concurrency:  1,
+				RowsPerRange: 10000,
+				PrefetchRows: 10000,
+				BatchRows:    2500,
+			},
+		},
+		{
+			product: "boards",
+			want: Settings{
+				Workers:      8,
+				Concurrency:  1,
+				RowsPerRange: 50000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.product, func(t *testing.T) {
+			got, err := TemplateSettings(tt.product)
+			if err != nil {
+				t.Fatalf("TemplateSettings() error = %v, want no error", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("TemplateSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTemplateExcludedTables(t *testing.T) {
+	b, err := readTemplate("")
+	if err != nil {
+		t.Fatalf("readTemplate() error = %v, want no error", err)
+	}
+
+	templ, err := template.New("cfg").Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		t.Fatalf("could not parse template: %v", err)
+	}
+
+	var out strings.Builder
+	if err = templ.Execute(&out, Parameters{PGSchema: "public", ExcludedTables: ExcludedTables}); err != nil {
+		t.Fatalf("could not execute template: %v", err)
+	}
+
+	want := "EXCLUDING TABLE NAMES MATCHING ~<IR_>, ~<focalboard>, ~<calls>, 'schema_migrations', 'db_migrations', 'db_lock', 'configurations', 'configurationfiles', 'db_config_migrations'\n"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("the configuration does not contain %q", want)
+	}
+}
+
+func TestTemplatesQuoteSchema(t *testing.T) {
+	for _, product := range []string{"", "boards", "playbooks", "calls"} {
+		b, err := readTemplate(product)
+		if err != nil {
+			t.Fatalf("readTemplate(%q) error = %v, want no error", product, err)
+		}
+
+		templ, err := template.New("cfg").Funcs(templateFuncs).Parse(string(b))
+		if err != nil {
+			t.Fatalf("could not parse template %q: %v", product, err)
+		}
+
+		var out strings.Builder
+		if err = templ.Execute(&out, Parameters{SourceSchema: "mattermost", PGSchema: "Team Chat"}); err != nil {
+			t.Fatalf("could not execute template %q: %v", product, err)
+		}
+
+		for _, want := range []string{`ALTER SCHEMA "Team Chat" RENAME TO mattermost;`, `ALTER SCHEMA mattermost RENAME TO "Team Chat";`} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("the configuration of %q does not contain %q", product, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// UniqueIndex is a unique index of a table in the MySQL database.
+type UniqueIndex struct {
+	Table   string
+	Name    string
+	Columns []IndexColumn
+}
+
+type IndexColumn struct {
+	Name string
+	// Collation is empty for the columns which are not textual.
+	Collation string
+	// SubPart is the length of the indexed prefix, zero if the whole column
+	// is indexed.
+	SubPart int
+}
+
+// isCaseInsensitive reports whether the collation ignores the case of the
+// characters.
+func (c IndexColumn) isCaseInsensitive() bool {
+	return strings.Contains(c.Collation, "_ci") || strings.Contains(c.Collation, "_ai")
+}
+
+// isAccentInsensitive reports whether the collation ignores the accents of
+// the characters. The _ci collations are accent insensitive unless they are
+// explicitly accent sensitive.
+func (c IndexColumn) isAccentInsensitive() bool {
+	return strings.Contains(c.Collation, "_ai") || (strings.Contains(c.Collation, "_ci") && !strings.Contains(c.Collation, "_as_"))
+}
+
+// value returns the indexed value of the column.
+func (c IndexColumn) value() string {
+	if c.SubPart > 0 {
+		return fmt.Sprintf("LEFT(`%s`, %d)", c.Name, c.SubPart)
+	}
+
+	return fmt.Sprintf("`%s`", c.Name)
+}
+
+// CaseInsensitiveColumns returns the columns of the index using a case or
+// accent insensitive collation. Postgres compares these byte-wise, so values
+// that are equal in MySQL become distinct after the migration.
+func (i UniqueIndex) CaseInsensitiveColumns() []string {
+	var columns []string
+	for _, c := range i.Columns {
+		if c.isCaseInsensitive() {
+			columns = append(columns, c.Name)
+		}
+	}
+
+	return columns
+}
+
+// GetUniqueTextIndexes returns the unique indexes having at least one textual
+// column. Primary keys and the indexes of the excluded tables are omitted.
+func (db *DB) GetUniqueTextIndexes(ctx context.Context, excluded []string) ([]UniqueIndex, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT s.TABLE_NAME, s.INDEX_NAME, s.COLUMN_NAME, COALESCE(c.COLLATION_NAME, ''), COALESCE(s.SUB_PART, 0) FROM information_schema.STATISTICS s
+		JOIN information_schema.COLUMNS c ON c.TABLE_SCHEMA = s.TABLE_SCHEMA AND c.TABLE_NAME = s.TABLE_NAME AND c.COLUMN_NAME = s.COLUMN_NAME
+		WHERE s.TABLE_SCHEMA = DATABASE() AND s.NON_UNIQUE = 0 AND s.INDEX_NAME != 'PRIMARY'
+		ORDER BY s.TABLE_NAME, s.INDEX_NAME, s.SEQ_IN_INDEX`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get unique indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []UniqueIndex
+	for rows.Next() {
+		var table, name string
+		var col IndexColumn
+		if err = rows.Scan(&table, &name, &col.Name, &col.Collation, &col.SubPart); err != nil {
+			return nil, fmt.Errorf("could not scan index: %w", err)
+		}
+		if slices.ContainsFunc(excluded, func(t string) bool { return strings.EqualFold(t, table) }) {
+			continue
+		}
+
+		if n := len(indexes); n > 0 && indexes[n-1].Table == table && indexes[n-1].Name == name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, col)
+			continue
+		}
+		indexes = append(indexes, UniqueIndex{Table: table, Name: name, Columns: []IndexColumn{col}})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get unique indexes: %w", err)
+	}
+
+	var textIndexes []UniqueIndex
+	for _, idx := range indexes {
+		for _, c := range idx.Columns {
+			if c.Collation != "" {
+				textIndexes = append(textIndexes, idx)
+				break
+			}
+		}
+	}
+
+	return textIndexes, nil
+}
+
+// collisionQuery groups the rows by the values of the index as Postgres would
+// compare them once the NUL characters are removed while loading the data.
+// The textual values are compared byte-wise, i.e. case and accent sensitively,
+// and only the indexed prefix of a column is compared.
+func (i UniqueIndex) collisionQuery(selects string) string {
+	exprs := make([]string, 0, len(i.Columns))
+	conditions := make([]string, 0, len(i.Columns))
+	for _, c := range i.Columns {
+		if c.Collation != "" {
+			exprs = append(exprs, fmt.Sprintf("REPLACE(CAST(%s AS BINARY), 0x00, '')", c.value()))
+		} else {
+			exprs = append(exprs, c.value())
+		}
+		// NULL values never collide in a unique index
+		conditions = append(conditions, fmt.Sprintf("`%s` IS NOT NULL", c.Name))
+	}
+
+	return fmt.Sprintf("SELECT %s FROM `%s` WHERE %s GROUP BY %s HAVING COUNT(*) > 1",
+		strings.Replace(selects, "{key}", strings.Join(exprs, ", "), 1), i.Table, strings.Join(conditions, " AND "), strings.Join(exprs, ", "))
+}
+
+// caseQuery selects the rows whose values of the case or accent insensitive
+// columns are not in their lower case and unaccented form. MySQL matches these
+// values regardless of the case and the accents, while Postgres only matches
+// them exactly.
+func (i UniqueIndex) caseQuery(selects string) string {
+	values := make([]string, 0, len(i.Columns))
+	var conditions []string
+	for _, c := range i.Columns {
+		values = append(values, c.value())
+		if !c.isCaseInsensitive() {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("CAST(%[1]s AS BINARY) != CAST(LOWER(%[1]s) AS BINARY)", c.value()))
+		if c.isAccentInsensitive() {
+			// the characters outside of ASCII are replaced by the conversion
+			conditions = append(conditions, fmt.Sprintf("CAST(%[1]s AS BINARY) != CAST(CONVERT(%[1]s USING ascii) AS BINARY)", c.value()))
+		}
+	}
+
+	return fmt.Sprintf("SELECT %s FROM `%s` WHERE %s",
+		strings.Replace(selects, "{key}", strings.Join(values, ", "), 1), i.Table, strings.Join(conditions, " OR "))
+}
+
+// CountUniqueCollisions returns the number of rows which would violate the
+// unique index in Postgres.
+func (db *DB) CountUniqueCollisions(ctx context.Context, index UniqueIndex) (int, error) {
+	return db.RunSelectCountQuery(ctx, fmt.Sprintf("SELECT COALESCE(SUM(n), 0) FROM (%s) d", index.collisionQuery("COUNT(*) AS n")))
+}
+
+// UniqueCollisionSamples returns a few of the values of the unique index which
+// would collide in Postgres.
+func (db *DB) UniqueCollisionSamples(ctx context.Context, index UniqueIndex) ([]string, error) {
+	return db.indexSamples(ctx, index, index.collisionQuery("CONCAT_WS(',', {key})"))
+}
+
+// CountCaseSensitiveRows returns the number of rows of the unique index which
+// Postgres compares differently as their values depend on the case or accent
+// insensitive collation. Zero is returned if the index has no such column.
+func (db *DB) CountCaseSensitiveRows(ctx context.Context, index UniqueIndex) (int, error) {
+	if len(index.CaseInsensitiveColumns()) == 0 {
+		return 0, nil
+	}
+
+	return db.RunSelectCountQuery(ctx, index.caseQuery("COUNT(*)"))
+}
+
+// CaseSensitiveSamples returns a few of the values of the unique index which
+// Postgres compares differently.
+func (db *DB) CaseSensitiveSamples(ctx context.Context, index UniqueIndex) ([]string, error) {
+	return db.indexSamples(ctx, index, index.caseQuery("CONCAT_WS(',', {key})"))
+}
+
+func (db *DB) indexSamples(ctx context.Context, index UniqueIndex, query string) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, query+fmt.Sprintf(" LIMIT %d", maxInvalidSamples))
+	if err != nil {
+		return nil, fmt.Errorf("could not get the values of %s: %w", index.Name, err)
+	}
+	defer rows.Close()
+
+	var samples []string
+	for rows.Next() {
+		var v []byte
+		if err = rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("could not scan the values of %s: %w", index.Name, err)
+		}
+		samples = append(samples, fmt.Sprintf("%q", v))
+	}
+
+	return samples, rows.Err()
+}
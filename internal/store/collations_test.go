@@ -0,0 +1,77 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCaseInsensitiveColumns(t *testing.T) {
+	idx := UniqueIndex{
+		Table: "Users",
+		Name:  "Username",
+		Columns: []IndexColumn{
+			{Name: "Username", Collation: "utf8mb4_general_ci"},
+			{Name: "Email", Collation: "utf8mb4_0900_ai_ci"},
+			{Name: "AuthData", Collation: "utf8mb4_0900_as_cs"},
+			{Name: "Nickname", Collation: "utf8mb4_bin"},
+			{Name: "CreateAt"},
+		},
+	}
+
+	want := []string{"Username", "Email"}
+	if got := idx.CaseInsensitiveColumns(); !slices.Equal(got, want) {
+		t.Errorf("CaseInsensitiveColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestCollisionQuery(t *testing.T) {
+	idx := UniqueIndex{
+		Table: "Channels",
+		Name:  "Name",
+		Columns: []IndexColumn{
+			{Name: "Name", Collation: "utf8mb4_general_ci", SubPart: 32},
+			{Name: "TeamId", Collation: "utf8mb4_general_ci"},
+			{Name: "DeleteAt"},
+		},
+	}
+
+	want := "SELECT COUNT(*) AS n FROM `Channels`" +
+		" WHERE `Name` IS NOT NULL AND `TeamId` IS NOT NULL AND `DeleteAt` IS NOT NULL" +
+		" GROUP BY REPLACE(CAST(LEFT(`Name`, 32) AS BINARY), 0x00, ''), REPLACE(CAST(`TeamId` AS BINARY), 0x00, ''), `DeleteAt` HAVING COUNT(*) > 1"
+	if got := idx.collisionQuery("COUNT(*) AS n"); got != want {
+		t.Errorf("collisionQuery() = %q, want %q", got, want)
+	}
+
+	want = "SELECT CONCAT_WS(',', REPLACE(CAST(LEFT(`Name`, 32) AS BINARY), 0x00, ''), REPLACE(CAST(`TeamId` AS BINARY), 0x00, ''), `DeleteAt`) FROM `Channels`" +
+		" WHERE `Name` IS NOT NULL AND `TeamId` IS NOT NULL AND `DeleteAt` IS NOT NULL" +
+		" GROUP BY REPLACE(CAST(LEFT(`Name`, 32) AS BINARY), 0x00, ''), REPLACE(CAST(`TeamId` AS BINARY), 0x00, ''), `DeleteAt` HAVING COUNT(*) > 1"
+	if got := idx.collisionQuery("CONCAT_WS(',', {key})"); got != want {
+		t.Errorf("collisionQuery() = %q, want %q", got, want)
+	}
+}
+
+func TestCaseQuery(t *testing.T) {
+	idx := UniqueIndex{
+		Table: "Users",
+		Name:  "Username",
+		Columns: []IndexColumn{
+			{Name: "Username", Collation: "utf8mb4_0900_as_ci", SubPart: 64},
+			{Name: "Email", Collation: "utf8mb4_general_ci"},
+			{Name: "AuthData", Collation: "utf8mb4_bin"},
+		},
+	}
+
+	want := "SELECT COUNT(*) FROM `Users` WHERE" +
+		" CAST(LEFT(`Username`, 64) AS BINARY) != CAST(LOWER(LEFT(`Username`, 64)) AS BINARY)" +
+		" OR CAST(`Email` AS BINARY) != CAST(LOWER(`Email`) AS BINARY)" +
+		" OR CAST(`Email` AS BINARY) != CAST(CONVERT(`Email` USING ascii) AS BINARY)"
+	if got := idx.caseQuery("COUNT(*)"); got != want {
+		t.Errorf("caseQuery() = %q, want %q", got, want)
+	}
+
+	want = "SELECT CONCAT_WS(',', LEFT(`Username`, 64), `Email`, `AuthData`) FROM `Users` WHERE"
+	if got := idx.caseQuery("CONCAT_WS(',', {key})"); !strings.HasPrefix(got, want) {
+		t.Errorf("caseQuery() = %q, want the prefix %q", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DateColumn is a date or time column of a table in the MySQL database.
+type DateColumn struct {
+	Table    string
+	Column   string
+	DataType string
+	Nullable bool
+}
+
+// zeroDateCondition matches the zero dates and the dates having a zero month or
+// day, which Postgres rejects.
+func (c DateColumn) zeroDateCondition() string {
+	return fmt.Sprintf("(YEAR(`%[1]s`) = 0 OR MONTH(`%[1]s`) = 0 OR DAY(`%[1]s`) = 0)", c.Column)
+}
+
+// GetDateColumns returns the date, datetime and timestamp columns of the
+// tables in the database except the excluded ones.
+func (db *DB) GetDateColumns(ctx context.Context, excluded []string) ([]DateColumn, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT c.TABLE_NAME, c.COLUMN_NAME, c.DATA_TYPE, c.IS_NULLABLE = 'YES' FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = DATABASE() AND t.TABLE_TYPE = 'BASE TABLE'
+		AND c.DATA_TYPE IN ('date', 'datetime', 'timestamp')
+		ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get date columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []DateColumn
+	for rows.Next() {
+		var c DateColumn
+		if err = rows.Scan(&c.Table, &c.Column, &c.DataType, &c.Nullable); err != nil {
+			return nil, fmt.Errorf("could not scan column: %w", err)
+		}
+		if slices.Contains(excluded, c.Table) {
+			continue
+		}
+		columns = append(columns, c)
+	}
+
+	return columns, rows.Err()
+}
+
+// CountZeroDates returns the number of rows having a zero date or a date with a
+// zero month or day in the column.
+func (db *DB) CountZeroDates(ctx context.Context, column DateColumn) (int, error) {
+	return db.RunSelectCountQuery(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE %s", column.Table, column.zeroDateCondition()))
+}
+
+// ZeroDateSamples returns the primary keys of a few rows counted by
+// CountZeroDates.
+func (db *DB) ZeroDateSamples(ctx context.Context, column DateColumn) ([]string, error) {
+	return db.sampleKeys(ctx, column.Table, column.zeroDateCondition())
+}
+
+// FixZeroDates sets the zero dates to NULL if the column is nullable. Otherwise
+// the zero parts of the dates are replaced with the lowest valid value, the
+// zero timestamps are set to the beginning of the Unix epoch.
+func (db *DB) FixZeroDates(ctx context.Context, column DateColumn) (int64, error) {
+	res, err := db.conn.ExecContext(ctx, column.fixZeroDatesQuery())
+	if err != nil {
+		return 0, fmt.Errorf("could not fix zero dates of %s.%s: %w", column.Table, column.Column, err)
+	}
+
+	return res.RowsAffected()
+}
+
+// fixZeroDatesQuery returns the UPDATE query of FixZeroDates. The lowest
+// timestamp is given as a Unix time, a literal would be interpreted in the time
+// zone of the session and may fall before the epoch.
+func (c DateColumn) fixZeroDatesQuery() string {
+	var value string
+	switch {
+	case c.Nullable:
+		value = "NULL"
+	case c.DataType == "timestamp":
+		value = "FROM_UNIXTIME(1)"
+	default:
+		value = fmt.Sprintf("CAST(CONCAT(LPAD(GREATEST(YEAR(`%[1]s`), 1), 4, '0'), '-', LPAD(GREATEST(MONTH(`%[1]s`), 1), 2, '0'), '-', LPAD(GREATEST(DAY(`%[1]s`), 1), 2, '0'), ' ', TIME(`%[1]s`)) AS DATETIME)", c.Column)
+	}
+
+	return fmt.Sprintf("UPDATE `%s` SET `%s` = %s WHERE %s", c.Table, c.Column, value, c.zeroDateCondition())
+}
+
+// sampleKeys returns the primary keys of a few rows of the table matching the
+// condition, formatted as col=value pairs.
+func (db *DB) sampleKeys(ctx context.Context, table, condition string) ([]string, error) {
+	pk, err := db.getPrimaryKey(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) == 0 {
+		return nil, nil
+	}
+
+	quoted := make([]string, 0, len(pk))
+	for _, col := range pk {
+		quoted = append(quoted, "`"+col+"`")
+	}
+
+	rows, err := db.conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM `%s` WHERE %s LIMIT %d", strings.Join(quoted, ", "), table, condition, maxInvalidSamples))
+	if err != nil {
+		return nil, fmt.Errorf("could not get the rows of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var samples []string
+	for rows.Next() {
+		key := make([]any, len(pk))
+		dest := make([]any, len(pk))
+		for i := range key {
+			dest[i] = &key[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("could not scan the rows of %s: %w", table, err)
+		}
+		samples = append(samples, formatKey(pk, key))
+	}
+
+	return samples, rows.Err()
+}
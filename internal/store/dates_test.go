@@ -0,0 +1,45 @@
+package store
+
+import "testing"
+
+func TestZeroDateCondition(t *testing.T) {
+	c := DateColumn{Table: "Jobs", Column: "StartAt", DataType: "datetime"}
+
+	want := "(YEAR(`StartAt`) = 0 OR MONTH(`StartAt`) = 0 OR DAY(`StartAt`) = 0)"
+	if got := c.zeroDateCondition(); got != want {
+		t.Errorf("zeroDateCondition() = %q, want %q", got, want)
+	}
+}
+
+func TestFixZeroDatesQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		column DateColumn
+		want   string
+	}{
+		{
+			name:   "nullable",
+			column: DateColumn{Table: "Jobs", Column: "StartAt", DataType: "datetime", Nullable: true},
+			want:   "UPDATE `Jobs` SET `StartAt` = NULL WHERE (YEAR(`StartAt`) = 0 OR MONTH(`StartAt`) = 0 OR DAY(`StartAt`) = 0)",
+		},
+		{
+			name:   "timestamp",
+			column: DateColumn{Table: "Jobs", Column: "CreatedAt", DataType: "timestamp"},
+			want:   "UPDATE `Jobs` SET `CreatedAt` = FROM_UNIXTIME(1) WHERE (YEAR(`CreatedAt`) = 0 OR MONTH(`CreatedAt`) = 0 OR DAY(`CreatedAt`) = 0)",
+		},
+		{
+			name:   "date",
+			column: DateColumn{Table: "Jobs", Column: "Day", DataType: "date"},
+			want: "UPDATE `Jobs` SET `Day` = CAST(CONCAT(LPAD(GREATEST(YEAR(`Day`), 1), 4, '0'), '-', LPAD(GREATEST(MONTH(`Day`), 1), 2, '0'), '-', LPAD(GREATEST(DAY(`Day`), 1), 2, '0'), ' ', TIME(`Day`)) AS DATETIME)" +
+				" WHERE (YEAR(`Day`) = 0 OR MONTH(`Day`) = 0 OR DAY(`Day`) = 0)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.column.fixZeroDatesQuery(); got != tt.want {
+				t.Errorf("fixZeroDatesQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package store
+
+import "errors"
+
+func freeDiskSpace(_ string) (int64, error) {
+	return 0, errors.New("not supported on this platform")
+}
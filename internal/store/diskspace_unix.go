@@ -0,0 +1,16 @@
+//go:build linux || darwin
+
+package store
+
+import "syscall"
+
+// freeDiskSpace returns the space available to unprivileged users on the
+// file system of the path.
+func freeDiskSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
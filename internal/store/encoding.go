@@ -0,0 +1,363 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxInvalidSamples is the number of keys reported for each column.
+	maxInvalidSamples = 10
+	// encodingFixBatchSize is the number of rows read and fixed in a single
+	// transaction.
+	encodingFixBatchSize = 1000
+)
+
+// ErrNoPrimaryKey is returned if the rows of a table can not be identified to
+// be reported or fixed.
+var ErrNoPrimaryKey = errors.New("table does not have a primary key")
+
+// TextColumn is a textual column of a table in the MySQL database.
+type TextColumn struct {
+	Table    string
+	Column   string
+	DataType string
+	Charset  string
+}
+
+// IsUTF8 returns true if MySQL stores the values of the column as UTF-8.
+func (c TextColumn) IsUTF8() bool {
+	return c.DataType == "json" || strings.HasPrefix(c.Charset, "utf8")
+}
+
+// InvalidValue is a value that is not valid UTF-8 along with the primary key
+// of its row.
+type InvalidValue struct {
+	Key   []any
+	Value []byte
+}
+
+// EncodingReport lists the values of a column that Postgres would reject. Only
+// the first few of the Count values are kept as samples.
+type EncodingReport struct {
+	Column     TextColumn
+	PrimaryKey []string
+	Count      int
+	Invalid    []InvalidValue
+}
+
+// Samples returns the primary keys of the sampled invalid values formatted as
+// col=value pairs.
+func (r *EncodingReport) Samples() []string {
+	samples := make([]string, 0, len(r.Invalid))
+	for _, v := range r.Invalid {
+		samples = append(samples, formatKey(r.PrimaryKey, v.Key))
+	}
+
+	return samples
+}
+
+// formatKey formats the primary key of a row as col=value pairs.
+func formatKey(columns []string, key []any) string {
+	pairs := make([]string, 0, len(columns))
+	for i, col := range columns {
+		v := key[i]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%v", col, v))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// GetTextColumns returns the char, text and json columns of the tables in the
+// database.
+func (db *DB) GetTextColumns(ctx context.Context) ([]TextColumn, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT c.TABLE_NAME, c.COLUMN_NAME, c.DATA_TYPE, COALESCE(c.CHARACTER_SET_NAME, '') FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = DATABASE() AND t.TABLE_TYPE = 'BASE TABLE'
+		AND c.DATA_TYPE IN ('char', 'varchar', 'tinytext', 'text', 'mediumtext', 'longtext', 'json')
+		ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get text columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []TextColumn
+	for rows.Next() {
+		var c TextColumn
+		if err = rows.Scan(&c.Table, &c.Column, &c.DataType, &c.Charset); err != nil {
+			return nil, fmt.Errorf("could not scan column: %w", err)
+		}
+		columns = append(columns, c)
+	}
+
+	return columns, rows.Err()
+}
+
+// FindInvalidUTF8 scans the given columns of a table and returns a report for
+// each column containing values that are not valid UTF-8. The table is read
+// once for all of the columns. ErrNoPrimaryKey is returned if the table does
+// not have a primary key.
+func (db *DB) FindInvalidUTF8(ctx context.Context, table string, columns []TextColumn) ([]*EncodingReport, error) {
+	pk, err := db.getPrimaryKey(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) == 0 {
+		return nil, fmt.Errorf("could not scan %s: %w", table, ErrNoPrimaryKey)
+	}
+
+	reports := make([]*EncodingReport, len(columns))
+	for i, col := range columns {
+		reports[i] = &EncodingReport{Column: col, PrimaryKey: pk}
+	}
+
+	_, _, err = db.scanInvalidUTF8(ctx, table, pk, columns, nil, 0, func(i int, v InvalidValue) {
+		reports[i].Count++
+		if len(reports[i].Invalid) < maxInvalidSamples {
+			reports[i].Invalid = append(reports[i].Invalid, v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*EncodingReport
+	for _, r := range reports {
+		if r.Count > 0 {
+			found = append(found, r)
+		}
+	}
+
+	return found, nil
+}
+
+// FixInvalidUTF8 replaces the invalid byte sequences of the values of the
+// reported column with the replacement. An empty replacement strips them. The
+// column is scanned again in batches ordered by the primary key as the report
+// only keeps a few samples, the invalid values of a batch are fixed in a
+// single transaction. A row is only updated if its value is still the one
+// that was read.
+func (db *DB) FixInvalidUTF8(ctx context.Context, report *EncodingReport, replacement string) (int64, error) {
+	col := report.Column
+
+	conditions := make([]string, 0, len(report.PrimaryKey)+1)
+	for _, pk := range report.PrimaryKey {
+		conditions = append(conditions, fmt.Sprintf("`%s` = ?", pk))
+	}
+	conditions = append(conditions, fmt.Sprintf("CAST(`%s` AS BINARY) = ?", col.Column))
+	query := fmt.Sprintf("UPDATE `%s` SET `%s` = ? WHERE %s", col.Table, col.Column, strings.Join(conditions, " AND "))
+
+	var fixed int64
+	var after []any
+	for {
+		// the updates can not be run on the connection while it is reading
+		// the rows, so the invalid values of the batch are collected first.
+		var invalid []InvalidValue
+		last, n, err := db.scanInvalidUTF8(ctx, col.Table, report.PrimaryKey, []TextColumn{col}, after, encodingFixBatchSize, func(_ int, v InvalidValue) {
+			invalid = append(invalid, v)
+		})
+		if err != nil {
+			return fixed, err
+		}
+
+		if len(invalid) > 0 {
+			n2, err2 := db.fixInvalidUTF8Batch(ctx, query, invalid, replacement)
+			fixed += n2
+			if err2 != nil {
+				return fixed, fmt.Errorf("could not fix %s.%s: %w", col.Table, col.Column, err2)
+			}
+		}
+
+		if n < encodingFixBatchSize {
+			return fixed, nil
+		}
+		after = last
+	}
+}
+
+// fixInvalidUTF8Batch runs the update query for each of the invalid values in
+// a transaction and returns the number of rows updated.
+func (db *DB) fixInvalidUTF8Batch(ctx context.Context, query string, invalid []InvalidValue, replacement string) (int64, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		// no-op if the transaction is committed
+		_ = tx.Rollback()
+	}()
+
+	var fixed int64
+	for _, v := range invalid {
+		args := append([]any{strings.ToValidUTF8(string(v.Value), replacement)}, v.Key...)
+		args = append(args, v.Value)
+		res, err2 := tx.ExecContext(ctx, query, args...)
+		if err2 != nil {
+			return 0, err2
+		}
+
+		n, err2 := res.RowsAffected()
+		if err2 != nil {
+			return 0, err2
+		}
+		fixed += n
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return fixed, nil
+}
+
+// scanQuery selects the primary key and the given columns of the rows of the
+// table. If limit is set, the rows are read in the order of the primary key,
+// the rows after the key given as arguments if after is set.
+func scanQuery(table string, pk []string, columns []TextColumn, after bool, limit int) string {
+	keys := make([]string, 0, len(pk))
+	for _, col := range pk {
+		keys = append(keys, "`"+col+"`")
+	}
+	selects := slices.Clone(keys)
+	for _, col := range columns {
+		selects = append(selects, fmt.Sprintf("CAST(`%s` AS BINARY)", col.Column))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM `%s`", strings.Join(selects, ", "), table)
+	if limit <= 0 {
+		return query
+	}
+	if after {
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(keys, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", "))
+	}
+
+	return query + fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), limit)
+}
+
+// scanInvalidUTF8 reads the primary key and the given columns of the rows of
+// the table and calls fn with the index of the column for each of the values
+// that are not valid UTF-8. If limit is set, at most limit rows following the
+// after key are read. The key of the last row and the number of rows read are
+// returned.
+func (db *DB) scanInvalidUTF8(ctx context.Context, table string, pk []string, columns []TextColumn, after []any, limit int, fn func(int, InvalidValue)) ([]any, int, error) {
+	rows, err := db.conn.QueryContext(ctx, scanQuery(table, pk, columns, len(after) > 0, limit), after...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not scan %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	keys := make([]sql.RawBytes, len(pk))
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, 0, len(pk)+len(columns))
+	for i := range keys {
+		dest = append(dest, &keys[i])
+	}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	var last []any
+	var n int
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, n, fmt.Errorf("could not scan %s: %w", table, err)
+		}
+		n++
+
+		var key []any
+		if limit > 0 {
+			key = copyKey(keys)
+			last = key
+		}
+
+		for i, v := range values {
+			if v == nil || utf8.Valid(v) {
+				continue
+			}
+			if key == nil {
+				key = copyKey(keys)
+			}
+			fn(i, InvalidValue{Key: key, Value: []byte(string(v))})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, n, fmt.Errorf("could not scan %s: %w", table, err)
+	}
+
+	return last, n, nil
+}
+
+// copyKey copies the scanned primary key, RawBytes are only valid until the
+// next scan.
+func copyKey(keys []sql.RawBytes) []any {
+	key := make([]any, len(keys))
+	for i, k := range keys {
+		key[i] = string(k)
+	}
+
+	return key
+}
+
+// EncodingFixPrivileges returns the privileges required to fix the encoding or
+// the NUL bytes of the given columns.
+func EncodingFixPrivileges(columns []TextColumn) []TablePrivilege {
+	var privileges []TablePrivilege
+	for _, c := range columns {
+		p := TablePrivilege{Privilege: "UPDATE", Table: c.Table}
+		if !slices.Contains(privileges, p) {
+			privileges = append(privileges, p)
+		}
+	}
+
+	return privileges
+}
+
+// CountNullBytes returns the number of rows containing NUL bytes in the column.
+func (db *DB) CountNullBytes(ctx context.Context, column TextColumn) (int, error) {
+	return db.RunSelectCountQuery(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE INSTR(CAST(`%s` AS BINARY), 0x00) > 0", column.Table, column.Column))
+}
+
+// RemoveNullBytes removes the NUL bytes from the values of the column and
+// returns the number of rows updated.
+func (db *DB) RemoveNullBytes(ctx context.Context, column TextColumn) (int64, error) {
+	if !identifierRegex.MatchString(column.Charset) {
+		return 0, fmt.Errorf("invalid character set %q for %s.%s", column.Charset, column.Table, column.Column)
+	}
+
+	res, err := db.conn.ExecContext(ctx, fmt.Sprintf("UPDATE `%[1]s` SET `%[2]s` = REPLACE(`%[2]s`, CONVERT(0x00 USING %[3]s), '') WHERE INSTR(CAST(`%[2]s` AS BINARY), 0x00) > 0",
+		column.Table, column.Column, column.Charset))
+	if err != nil {
+		return 0, fmt.Errorf("could not remove NUL bytes from %s.%s: %w", column.Table, column.Column, err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (db *DB) getPrimaryKey(ctx context.Context, table string) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+		ORDER BY ORDINAL_POSITION`, table)
+	if err != nil {
+		return nil, fmt.Errorf("could not get primary key of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			return nil, fmt.Errorf("could not scan primary key of %s: %w", table, err)
+		}
+		columns = append(columns, col)
+	}
+
+	return columns, rows.Err()
+}
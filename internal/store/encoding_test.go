@@ -0,0 +1,42 @@
+package store
+
+import "testing"
+
+func TestScanQuery(t *testing.T) {
+	columns := []TextColumn{{Table: "Posts", Column: "Message"}, {Table: "Posts", Column: "Props"}}
+
+	tests := []struct {
+		name  string
+		pk    []string
+		after bool
+		limit int
+		want  string
+	}{
+		{
+			name: "whole table",
+			pk:   []string{"Id"},
+			want: "SELECT `Id`, CAST(`Message` AS BINARY), CAST(`Props` AS BINARY) FROM `Posts`",
+		},
+		{
+			name:  "first batch",
+			pk:    []string{"Id"},
+			limit: 1000,
+			want:  "SELECT `Id`, CAST(`Message` AS BINARY), CAST(`Props` AS BINARY) FROM `Posts` ORDER BY `Id` LIMIT 1000",
+		},
+		{
+			name:  "next batch of a composite key",
+			pk:    []string{"ChannelId", "UserId"},
+			after: true,
+			limit: 1000,
+			want:  "SELECT `ChannelId`, `UserId`, CAST(`Message` AS BINARY), CAST(`Props` AS BINARY) FROM `Posts` WHERE (`ChannelId`, `UserId`) > (?, ?) ORDER BY `ChannelId`, `UserId` LIMIT 1000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanQuery("Posts", tt.pk, columns, tt.after, tt.limit); got != tt.want {
+				t.Errorf("scanQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
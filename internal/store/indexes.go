@@ -0,0 +1,454 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/mattermost/migration-assist/internal/logger"
+)
+
+var (
+	createIndexRegex   = regexp.MustCompile(`(?is)^\s*CREATE\s+INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)\s+ON\s+`)
+	createIndexPrefix  = regexp.MustCompile(`(?i)^\s*CREATE\s+INDEX\s+`)
+	fulltextIndexRegex = regexp.MustCompile(`(?is)^\s*CREATE\s+INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)\s+ON\s+(\w+)\s+USING\s+gin\s*\(\s*to_tsvector\s*\(\s*'(\w+)'\s*,\s*(\w+)\s*\)\s*\)`)
+)
+
+const (
+	// tsvectorCandidateBytes is the length of the values checked for a
+	// tsvector overflow. A tsvector is limited to 1MB, which may be exceeded
+	// by a value of a quarter of its size consisting of distinct words.
+	tsvectorCandidateBytes = 1 << 18
+)
+
+// TsvectorStrategy is the way the values too long for a tsvector are handled.
+type TsvectorStrategy string
+
+const (
+	// TsvectorExclude leaves the values as they are and creates a partial
+	// index without the rows.
+	TsvectorExclude TsvectorStrategy = "exclude"
+	// TsvectorTruncate truncates the values to the longest prefix fitting into
+	// a tsvector, the original values are kept in the backup table.
+	TsvectorTruncate TsvectorStrategy = "truncate"
+)
+
+// TsvectorBackupTable keeps the original values of the rows truncated to fit
+// into a tsvector, so that they can be restored.
+const TsvectorBackupTable = "migration_assist_tsvector_backup"
+
+// errCodeProgramLimitExceeded is the code of the error returned by Postgres if
+// a string is too long for tsvector.
+const errCodeProgramLimitExceeded = "54000"
+
+// FulltextIndex is a full text index on a text column.
+type FulltextIndex struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Config string `json:"config"`
+}
+
+// ParseFulltextIndex parses a CREATE INDEX query of a gin index on the
+// to_tsvector of a column.
+func ParseFulltextIndex(query string) (FulltextIndex, bool) {
+	m := fulltextIndexRegex.FindStringSubmatch(query)
+	if m == nil {
+		return FulltextIndex{}, false
+	}
+
+	return FulltextIndex{Name: m[1], Table: m[2], Config: m[3], Column: m[4]}, true
+}
+
+// TsvectorRow is a row whose value is too long for a tsvector.
+type TsvectorRow struct {
+	ID     string `json:"id"`
+	Length int    `json:"length"`
+	// TruncatedLength is the length of the value after the truncation.
+	TruncatedLength int `json:"truncated_length,omitempty"`
+}
+
+// TsvectorFix records the rows excluded or truncated to create an index. The
+// original values of the truncated rows are kept in the backup table.
+type TsvectorFix struct {
+	Index       FulltextIndex    `json:"index"`
+	Strategy    TsvectorStrategy `json:"strategy"`
+	BackupTable string           `json:"backup_table,omitempty"`
+	Rows        []TsvectorRow    `json:"rows"`
+}
+
+// TsvectorOverflowError is returned if an index could not be created as some
+// of the values are too long for a tsvector.
+type TsvectorOverflowError struct {
+	Index FulltextIndex
+	Rows  []TsvectorRow
+	Err   error
+}
+
+func (e *TsvectorOverflowError) Error() string {
+	return fmt.Sprintf("%d row(s) of %s.%s are too long for tsvector: %s", len(e.Rows), e.Index.Table, e.Index.Column, e.Err)
+}
+
+func (e *TsvectorOverflowError) Unwrap() error {
+	return e.Err
+}
+
+// IndexOptions are the options of the index creation.
+type IndexOptions struct {
+	// Concurrently creates the indexes without locking the tables against
+	// writes. An index failing to be created concurrently is left invalid,
+	// which is dropped before the next attempt.
+	Concurrently bool
+	// MaintenanceWorkMem is set for the session if it is not empty, e.g. 1GB.
+	MaintenanceWorkMem string
+	// ProgressInterval is the interval of the progress reports, the progress
+	// is not reported if it is zero.
+	ProgressInterval time.Duration
+	// Excluded are the ids of the rows excluded from the full text indexes,
+	// keyed by the name of the index.
+	Excluded map[string][]string
+}
+
+// CreateIndexes runs the CREATE INDEX queries found in dir one by one. The
+// invalid indexes left by an earlier attempt are dropped before they are
+// created again, so are the partial indexes of an earlier run unless the rows
+// are excluded again. If a full text index fails due to the values too long for a
+// tsvector, a *TsvectorOverflowError with the offending rows is returned.
+func (db *DB) CreateIndexes(ctx context.Context, assets fs.FS, dir string, opts IndexOptions, baseLogger logger.LogInterface) error {
+	entries, err := fs.ReadDir(assets, dir)
+	if err != nil {
+		return err
+	}
+
+	if opts.MaintenanceWorkMem != "" {
+		if _, err = db.conn.ExecContext(ctx, "SELECT set_config('maintenance_work_mem', $1, false)", opts.MaintenanceWorkMem); err != nil {
+			return fmt.Errorf("could not set maintenance_work_mem: %w", err)
+		}
+	}
+
+	var pid int
+	if err = db.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return fmt.Errorf("could not get backend pid: %w", err)
+	}
+
+	for _, e := range entries {
+		b, err2 := fs.ReadFile(assets, path.Join(dir, e.Name()))
+		if err2 != nil {
+			return fmt.Errorf("could not read embedded sql file: %w", err2)
+		}
+
+		query := string(b)
+		m := createIndexRegex.FindStringSubmatch(query)
+		if m == nil {
+			return fmt.Errorf("%s is not a CREATE INDEX query", e.Name())
+		}
+		name := m[1]
+
+		invalid, err2 := db.isInvalidIndex(ctx, name)
+		if err2 != nil {
+			return err2
+		}
+		if invalid {
+			baseLogger.Printf("dropping the invalid index %s left by an earlier attempt\n", name)
+			if err2 = db.dropIndex(ctx, name, opts.Concurrently); err2 != nil {
+				return err2
+			}
+		}
+
+		excluded := opts.Excluded[name]
+		if len(excluded) == 0 {
+			partial, err3 := db.isPartialIndex(ctx, name)
+			if err3 != nil {
+				return err3
+			}
+			if partial {
+				baseLogger.Printf("dropping the partial index %s left by an earlier run to create it on all of the rows\n", name)
+				if err3 = db.dropIndex(ctx, name, opts.Concurrently); err3 != nil {
+					return err3
+				}
+			}
+		}
+
+		if opts.Concurrently {
+			query = createIndexPrefix.ReplaceAllString(query, "CREATE INDEX CONCURRENTLY ")
+		}
+		if len(excluded) > 0 {
+			query = excludeRows(query, excluded)
+		}
+
+		baseLogger.Printf("applying %s\n", e.Name())
+		stop := db.reportIndexProgress(ctx, pid, name, opts.ProgressInterval, baseLogger)
+		_, err2 = db.conn.ExecContext(ctx, query)
+		stop()
+		if err2 != nil {
+			idx, ok := ParseFulltextIndex(string(b))
+			if !ok || !isProgramLimitExceeded(err2) {
+				return fmt.Errorf("could not create index %s: %w", name, err2)
+			}
+
+			baseLogger.Printf("looking for the values of %s.%s too long for tsvector...\n", idx.Table, idx.Column)
+			rows, err3 := db.FindTsvectorOverflow(ctx, idx, excluded)
+			if err3 != nil {
+				return err3
+			}
+
+			return &TsvectorOverflowError{Index: idx, Rows: rows, Err: err2}
+		}
+	}
+
+	return nil
+}
+
+// excludeRows adds a condition to the CREATE INDEX query to exclude the rows
+// of the given ids.
+func excludeRows(query string, ids []string) string {
+	quoted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		quoted = append(quoted, pq.QuoteLiteral(id))
+	}
+
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	return fmt.Sprintf("%s WHERE id NOT IN (%s);", query, strings.Join(quoted, ", "))
+}
+
+// isProgramLimitExceeded reports whether the error is a Postgres error with
+// the program_limit_exceeded code, e.g. a string too long for tsvector.
+func isProgramLimitExceeded(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == errCodeProgramLimitExceeded
+}
+
+// FindTsvectorOverflow returns the rows whose value is too long for a
+// tsvector. The rows of the excluded ids are skipped.
+func (db *DB) FindTsvectorOverflow(ctx context.Context, idx FulltextIndex, excluded []string) ([]TsvectorRow, error) {
+	rows, err := db.conn.QueryContext(ctx, fmt.Sprintf("SELECT id, char_length(%[2]s) FROM %[1]s WHERE octet_length(%[2]s) > $1 ORDER BY id", pq.QuoteIdentifier(idx.Table), pq.QuoteIdentifier(idx.Column)), tsvectorCandidateBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the long values of %s.%s: %w", idx.Table, idx.Column, err)
+	}
+
+	var candidates []TsvectorRow
+	for rows.Next() {
+		var r TsvectorRow
+		if err = rows.Scan(&r.ID, &r.Length); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan row: %w", err)
+		}
+		if !slices.Contains(excluded, r.ID) {
+			candidates = append(candidates, r)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get the long values of %s.%s: %w", idx.Table, idx.Column, err)
+	}
+
+	var overflow []TsvectorRow
+	for _, r := range candidates {
+		fits, err2 := db.fitsTsvector(ctx, idx, r.ID, r.Length)
+		if err2 != nil {
+			return nil, err2
+		}
+		if !fits {
+			overflow = append(overflow, r)
+		}
+	}
+
+	return overflow, nil
+}
+
+// fitsTsvector reports whether the tsvector of the first n characters of the
+// value of the row is within the limits.
+func (db *DB) fitsTsvector(ctx context.Context, idx FulltextIndex, id string, n int) (bool, error) {
+	var length int
+	err := db.conn.QueryRowContext(ctx, fmt.Sprintf("SELECT length(to_tsvector($1::regconfig, left(%s, $2))) FROM %s WHERE id = $3", pq.QuoteIdentifier(idx.Column), pq.QuoteIdentifier(idx.Table)), idx.Config, n, id).Scan(&length)
+	if isProgramLimitExceeded(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not check row %s of %s: %w", id, idx.Table, err)
+	}
+
+	return true, nil
+}
+
+// FixTsvectorOverflow applies the strategy to the rows. The excluded rows are
+// left as they are and should be passed to CreateIndexes. The truncated values
+// are cut to the longest prefix fitting into a tsvector, the original value of
+// each row is copied to the backup table in the same transaction as the
+// truncation.
+func (db *DB) FixTsvectorOverflow(ctx context.Context, idx FulltextIndex, rows []TsvectorRow, strategy TsvectorStrategy) (TsvectorFix, error) {
+	fix := TsvectorFix{Index: idx, Strategy: strategy}
+	switch strategy {
+	case TsvectorExclude:
+		fix.Rows = rows
+		return fix, nil
+	case TsvectorTruncate:
+		fix.BackupTable = TsvectorBackupTable
+	default:
+		return fix, fmt.Errorf("unsupported tsvector strategy: %s", strategy)
+	}
+
+	_, err := db.conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		table_name text NOT NULL,
+		column_name text NOT NULL,
+		id varchar(26) NOT NULL,
+		value text,
+		created_at timestamptz NOT NULL DEFAULT now()
+	)`, TsvectorBackupTable))
+	if err != nil {
+		return fix, fmt.Errorf("could not create the backup table: %w", err)
+	}
+
+	for _, r := range rows {
+		// the longest prefix is searched between a fitting and an
+		// overflowing length.
+		lo, hi := 0, r.Length
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			fits, err2 := db.fitsTsvector(ctx, idx, r.ID, mid)
+			if err2 != nil {
+				return fix, err2
+			}
+			if fits {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		if err = db.truncateTsvectorRow(ctx, idx, r.ID, lo); err != nil {
+			return fix, err
+		}
+		r.TruncatedLength = lo
+		fix.Rows = append(fix.Rows, r)
+	}
+
+	return fix, nil
+}
+
+// truncateTsvectorRow copies the value of the row to the backup table and
+// truncates it to n characters.
+func (db *DB) truncateTsvectorRow(ctx context.Context, idx FulltextIndex, id string, n int) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		// no-op if the transaction is committed
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %[1]s (table_name, column_name, id, value) SELECT $1, $2, id, %[3]s FROM %[2]s WHERE id = $3", TsvectorBackupTable, pq.QuoteIdentifier(idx.Table), pq.QuoteIdentifier(idx.Column)), idx.Table, idx.Column, id)
+	if err != nil {
+		return fmt.Errorf("could not back up row %s of %s: %w", id, idx.Table, err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %[1]s SET %[2]s = left(%[2]s, $1) WHERE id = $2", pq.QuoteIdentifier(idx.Table), pq.QuoteIdentifier(idx.Column)), n, id)
+	if err != nil {
+		return fmt.Errorf("could not truncate row %s of %s: %w", id, idx.Table, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// isInvalidIndex reports whether the index exists in the search path but is
+// marked as invalid, e.g. after a failed concurrent creation.
+func (db *DB) isInvalidIndex(ctx context.Context, name string) (bool, error) {
+	var valid bool
+	err := db.conn.QueryRowContext(ctx, "SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)", name).Scan(&valid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not check index %s: %w", name, err)
+	}
+
+	return !valid, nil
+}
+
+// isPartialIndex reports whether the index exists in the search path with a
+// condition, e.g. one created without the rows too long for a tsvector.
+func (db *DB) isPartialIndex(ctx context.Context, name string) (bool, error) {
+	var partial bool
+	err := db.conn.QueryRowContext(ctx, "SELECT indpred IS NOT NULL FROM pg_index WHERE indexrelid = to_regclass($1)", name).Scan(&partial)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not check index %s: %w", name, err)
+	}
+
+	return partial, nil
+}
+
+func (db *DB) dropIndex(ctx context.Context, name string, concurrently bool) error {
+	query := "DROP INDEX IF EXISTS " + name
+	if concurrently {
+		query = "DROP INDEX CONCURRENTLY IF EXISTS " + name
+	}
+
+	if _, err := db.conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("could not drop index %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// reportIndexProgress logs the progress of the index creation of the backend
+// periodically until the returned function is called. The progress is read
+// through the pool as the connection is busy creating the index.
+func (db *DB) reportIndexProgress(ctx context.Context, pid int, name string, interval time.Duration, baseLogger logger.LogInterface) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var phase string
+			var blocksDone, blocksTotal, tuplesDone, tuplesTotal int64
+			err := db.db.QueryRowContext(ctx, `SELECT phase, blocks_done, blocks_total, tuples_done, tuples_total
+				FROM pg_stat_progress_create_index WHERE pid = $1`, pid).Scan(&phase, &blocksDone, &blocksTotal, &tuplesDone, &tuplesTotal)
+			if err != nil {
+				// the creation may have just completed
+				continue
+			}
+
+			switch {
+			case blocksTotal > 0:
+				baseLogger.Printf("creating %s: %s, %.1f%% of the blocks\n", name, phase, float64(blocksDone)/float64(blocksTotal)*100)
+			case tuplesTotal > 0:
+				baseLogger.Printf("creating %s: %s, %.1f%% of the tuples\n", name, phase, float64(tuplesDone)/float64(tuplesTotal)*100)
+			default:
+				baseLogger.Printf("creating %s: %s\n", name, phase)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestCreateIndexRegex(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "CREATE INDEX IF NOT EXISTS idx_posts_message_txt ON posts USING gin(to_tsvector('english', message));", want: "idx_posts_message_txt"},
+		{query: "\ncreate index idx_fileinfo_content_txt on fileinfo using gin(to_tsvector('english', content));", want: "idx_fileinfo_content_txt"},
+		{query: "CREATE UNIQUE INDEX idx_users_email ON users (email);"},
+	}
+
+	for _, tt := range tests {
+		var got string
+		if m := createIndexRegex.FindStringSubmatch(tt.query); m != nil {
+			got = m[1]
+		}
+		if got != tt.want {
+			t.Errorf("createIndexRegex on %q = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	got := createIndexPrefix.ReplaceAllString(tests[0].query, "CREATE INDEX CONCURRENTLY ")
+	if want := "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_posts_message_txt ON posts USING gin(to_tsvector('english', message));"; got != want {
+		t.Errorf("createIndexPrefix replaced = %q, want %q", got, want)
+	}
+}
+
+func TestParseFulltextIndex(t *testing.T) {
+	query := "CREATE INDEX IF NOT EXISTS idx_posts_message_txt ON posts USING gin(to_tsvector('english', message));"
+
+	got, ok := ParseFulltextIndex(query)
+	if !ok {
+		t.Fatalf("ParseFulltextIndex() did not match %q", query)
+	}
+	if want := (FulltextIndex{Name: "idx_posts_message_txt", Table: "posts", Column: "message", Config: "english"}); got != want {
+		t.Errorf("ParseFulltextIndex() = %+v, want %+v", got, want)
+	}
+
+	if _, ok = ParseFulltextIndex("CREATE INDEX idx_posts_createat ON posts (createat);"); ok {
+		t.Errorf("ParseFulltextIndex() matched an index that is not a full text index")
+	}
+
+	excluded := excludeRows(query, []string{"a", "b'c"})
+	if want := "CREATE INDEX IF NOT EXISTS idx_posts_message_txt ON posts USING gin(to_tsvector('english', message)) WHERE id NOT IN ('a', 'b''c');"; excluded != want {
+		t.Errorf("excludeRows() = %q, want %q", excluded, want)
+	}
+}
+
+func TestIsProgramLimitExceeded(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "tsvector overflow", err: fmt.Errorf("could not create index: %w", &pq.Error{Code: "54000", Message: "string is too long for tsvector (1048577 bytes, max 1048575 bytes)"}), want: true},
+		{name: "other postgres error", err: &pq.Error{Code: "42P01", Message: "relation \"posts\" does not exist"}, want: false},
+		{name: "message only", err: errors.New("string is too long for tsvector"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isProgramLimitExceeded(tt.err); got != tt.want {
+				t.Errorf("isProgramLimitExceeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
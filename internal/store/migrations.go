@@ -0,0 +1,185 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/morph/models"
+)
+
+var (
+	// knownNameMismatches lists the migrations having different names in the
+	// MySQL and Postgres sets on purpose. The pgloader configuration renames
+	// these after the data is loaded.
+	knownNameMismatches = map[int]bool{
+		92: true, // add_createat_to_teammembers vs add_createat_to_teamembers
+	}
+)
+
+type MigrationNameMismatch struct {
+	Version  int
+	MySQL    string
+	Postgres string
+}
+
+// MigrationValidationError describes the differences between the migrations
+// applied to the MySQL database and the Postgres migrations that are going to
+// be applied to the target database.
+type MigrationValidationError struct {
+	// Missing are the applied versions that do not have a Postgres counterpart.
+	Missing []int
+	// Extra are the Postgres versions within the applied range that were never
+	// applied to the MySQL database.
+	Extra []int
+	// Mismatched are the versions having different names in the MySQL and
+	// Postgres migration sets.
+	Mismatched []MigrationNameMismatch
+}
+
+func (e *MigrationValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("applied migrations do not match the Postgres migrations")
+	if len(e.Missing) > 0 {
+		fmt.Fprintf(&sb, "\n  missing from the Postgres migrations: %v", e.Missing)
+	}
+	if len(e.Extra) > 0 {
+		fmt.Fprintf(&sb, "\n  not applied to the MySQL database: %v", e.Extra)
+	}
+	for _, m := range e.Mismatched {
+		fmt.Fprintf(&sb, "\n  version %d is named %q in MySQL and %q in Postgres", m.Version, m.MySQL, m.Postgres)
+	}
+	sb.WriteString("\nthe MySQL database is probably on a newer or a modified Mattermost version than the one supported by this tool," +
+		" consider supplying the migrations with --mattermost-version or --migrations-dir instead")
+
+	return sb.String()
+}
+
+// ValidateAppliedMigrations compares the applied versions with the up
+// migrations found in mysqlDir and postgresDir of the given file system.
+// A *MigrationValidationError is returned if any of the versions is missing,
+// extra or named differently between the sets.
+func ValidateAppliedMigrations(assets fs.FS, mysqlDir, postgresDir string, versions []int) error {
+	mysqlMigrations, err := upMigrationNames(assets, mysqlDir)
+	if err != nil {
+		return fmt.Errorf("could not read mysql migrations: %w", err)
+	}
+
+	postgresMigrations, err := upMigrationNames(assets, postgresDir)
+	if err != nil {
+		return fmt.Errorf("could not read postgres migrations: %w", err)
+	}
+
+	var verr MigrationValidationError
+	for _, v := range versions {
+		pgName, ok := postgresMigrations[v]
+		if !ok {
+			verr.Missing = append(verr.Missing, v)
+			continue
+		}
+
+		mysqlName, ok := mysqlMigrations[v]
+		if !ok || knownNameMismatches[v] {
+			continue
+		}
+
+		if mysqlName != pgName {
+			verr.Mismatched = append(verr.Mismatched, MigrationNameMismatch{
+				Version:  v,
+				MySQL:    mysqlName,
+				Postgres: pgName,
+			})
+		}
+	}
+
+	if len(versions) > 0 {
+		latest := slices.Max(versions)
+		for v := range postgresMigrations {
+			if v < latest && !slices.Contains(versions, v) {
+				verr.Extra = append(verr.Extra, v)
+			}
+		}
+		slices.Sort(verr.Extra)
+	}
+
+	if len(verr.Missing) > 0 || len(verr.Extra) > 0 || len(verr.Mismatched) > 0 {
+		return &verr
+	}
+
+	return nil
+}
+
+// HashMigrations returns a checksum of the up migrations of the given versions
+// found in dir. Both the file names and the contents are taken into account.
+func HashMigrations(assets fs.FS, dir string, versions []int) (string, error) {
+	migrations, err := upMigrations(assets, dir)
+	if err != nil {
+		return "", err
+	}
+
+	sorted := slices.Clone(versions)
+	slices.Sort(sorted)
+
+	h := sha256.New()
+	for _, v := range sorted {
+		m, ok := migrations[v]
+		if !ok {
+			return "", fmt.Errorf("could not find the migration for version %d in %s", v, dir)
+		}
+
+		b, err2 := fs.ReadFile(assets, path.Join(dir, m.RawName))
+		if err2 != nil {
+			return "", fmt.Errorf("could not read migration: %w", err2)
+		}
+
+		fmt.Fprintf(h, "%s\n", m.RawName)
+		h.Write(b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func upMigrationNames(assets fs.FS, dir string) (map[int]string, error) {
+	migrations, err := upMigrations(assets, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string, len(migrations))
+	for v, m := range migrations {
+		names[v] = m.Name
+	}
+
+	return names, nil
+}
+
+// upMigrations parses the file names in dir and returns the up migrations by
+// their versions. The contents of the files are not read.
+func upMigrations(assets fs.FS, dir string) (map[int]*models.Migration, error) {
+	entries, err := fs.ReadDir(assets, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[int]*models.Migration)
+	for _, e := range entries {
+		m, err2 := models.NewMigration(io.NopCloser(bytes.NewReader(nil)), e.Name())
+		if err2 != nil {
+			return nil, fmt.Errorf("could not parse migration: %w", err2)
+		}
+
+		if m.Direction != models.Up {
+			continue
+		}
+
+		migrations[int(m.Version)] = m
+	}
+
+	return migrations, nil
+}
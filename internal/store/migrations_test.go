@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mattermost/migration-assist/queries"
+)
+
+func TestValidateAppliedMigrations(t *testing.T) {
+	assets := fstest.MapFS{
+		"mysql/000001_create_teams.up.sql":                {},
+		"mysql/000001_create_teams.down.sql":              {},
+		"mysql/000002_create_team_members.up.sql":         {},
+		"mysql/000003_create_cluster_discovery.up.sql":    {},
+		"postgres/000001_create_teams.up.sql":             {},
+		"postgres/000001_create_teams.down.sql":           {},
+		"postgres/000002_create_team_member.up.sql":       {},
+		"postgres/000003_create_cluster_discovery.up.sql": {},
+		"postgres/000004_create_command_webhooks.up.sql":  {},
+	}
+
+	tests := []struct {
+		name           string
+		versions       []int
+		wantMissing    []int
+		wantExtra      []int
+		wantMismatched []int
+	}{
+		{
+			name:      "skipped version",
+			versions:  []int{1, 3, 4},
+			wantExtra: []int{2},
+		},
+		{
+			name:           "name mismatch",
+			versions:       []int{1, 2},
+			wantMismatched: []int{2},
+		},
+		{
+			name:        "missing version",
+			versions:    []int{1, 3, 4, 5},
+			wantMissing: []int{5},
+			wantExtra:   []int{2},
+		},
+		{
+			name:     "valid subset",
+			versions: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAppliedMigrations(assets, "mysql", "postgres", tt.versions)
+			if tt.wantMissing == nil && tt.wantExtra == nil && tt.wantMismatched == nil {
+				if err != nil {
+					t.Errorf("ValidateAppliedMigrations() error = %v, want no error", err)
+				}
+				return
+			}
+
+			var verr *MigrationValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateAppliedMigrations() error = %v, want *MigrationValidationError", err)
+			}
+
+			if !slices.Equal(verr.Missing, tt.wantMissing) {
+				t.Errorf("ValidateAppliedMigrations() Missing = %v, want %v", verr.Missing, tt.wantMissing)
+			}
+			if !slices.Equal(verr.Extra, tt.wantExtra) {
+				t.Errorf("ValidateAppliedMigrations() Extra = %v, want %v", verr.Extra, tt.wantExtra)
+			}
+			var mismatched []int
+			for _, m := range verr.Mismatched {
+				mismatched = append(mismatched, m.Version)
+			}
+			if !slices.Equal(mismatched, tt.wantMismatched) {
+				t.Errorf("ValidateAppliedMigrations() Mismatched = %v, want %v", mismatched, tt.wantMismatched)
+			}
+		})
+	}
+}
+
+func TestValidateAppliedMigrationsEmbedded(t *testing.T) {
+	names, err := upMigrationNames(queries.Assets(), "migrations/mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := make([]int, 0, len(names))
+	for v := range names {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+
+	err = ValidateAppliedMigrations(queries.Assets(), "migrations/mysql", "migrations/postgres", versions)
+	if err != nil {
+		t.Errorf("ValidateAppliedMigrations() error = %v, want no error", err)
+	}
+}
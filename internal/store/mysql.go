@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -69,6 +70,49 @@ func appendMultipleStatementsFlag(dataSource string) (string, error) {
 	return config.FormatDSN(), nil
 }
 
+// GetMySQLVersion returns the version of the MySQL server.
+func (db *DB) GetMySQLVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := db.conn.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+// GetSystemValue returns the value of the given key from the Systems table. An
+// empty string is returned if the key does not exist.
+func (db *DB) GetSystemValue(ctx context.Context, name string) (string, error) {
+	var value string
+	err := db.conn.QueryRowContext(ctx, "SELECT Value FROM Systems WHERE Name = ?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// MissingProcedures returns the procedures of the given names that do not
+// exist in the current database.
+func (db *DB) MissingProcedures(ctx context.Context, names []string) ([]string, error) {
+	var missing []string
+	for _, name := range names {
+		var count int
+		err := db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_TYPE = 'PROCEDURE' AND ROUTINE_NAME = ?", name).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("could not check procedure %s: %w", name, err)
+		}
+
+		if count == 0 {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing, nil
+}
+
 func CompareMySQL(a, b *DB, baseLogger, verboseLogger logger.LogInterface, saveDiff bool) error {
 	testConn, err := b.GetDB().Conn(context.TODO())
 	if err != nil {
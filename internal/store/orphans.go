@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var countQueryRegex = regexp.MustCompile(`(?is)^\s*SELECT\s+COUNT\(\*\)\s+FROM\s+(\w+)\s+WHERE\s+(.+?)\s*;?\s*$`)
+
+// parseCountQuery returns the table and the condition of a
+// SELECT COUNT(*) FROM table WHERE condition query.
+func parseCountQuery(query string) (string, string, bool) {
+	m := countQueryRegex.FindStringSubmatch(query)
+	if m == nil {
+		return "", "", false
+	}
+
+	return m[1], m[2], true
+}
+
+// OrphanSamples returns the primary keys of a few of the rows counted by the
+// given orphan check query.
+func (db *DB) OrphanSamples(ctx context.Context, query string) ([]string, error) {
+	table, condition, ok := parseCountQuery(query)
+	if !ok {
+		return nil, fmt.Errorf("not a count query: %s", strings.TrimSpace(query))
+	}
+
+	return db.sampleKeys(ctx, table, condition)
+}
@@ -0,0 +1,34 @@
+package store
+
+import "testing"
+
+func TestParseCountQuery(t *testing.T) {
+	tests := []struct {
+		query         string
+		wantTable     string
+		wantCondition string
+		wantOK        bool
+	}{
+		{
+			query:         "SELECT COUNT(*) FROM Reactions WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = Reactions.PostId);\n",
+			wantTable:     "Reactions",
+			wantCondition: "NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = Reactions.PostId)",
+			wantOK:        true,
+		},
+		{
+			query:         "select count(*) from FileInfo where NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = FileInfo.PostId)\nAND FileInfo.PostId != ''",
+			wantTable:     "FileInfo",
+			wantCondition: "NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = FileInfo.PostId)\nAND FileInfo.PostId != ''",
+			wantOK:        true,
+		},
+		{query: "CALL CheckInvalidJSON('Posts', 'Props');"},
+		{query: "SELECT COUNT(*) FROM Posts;"},
+	}
+
+	for _, tt := range tests {
+		table, condition, ok := parseCountQuery(tt.query)
+		if ok != tt.wantOK || table != tt.wantTable || condition != tt.wantCondition {
+			t.Errorf("parseCountQuery(%q) = %q, %q, %v, want %q, %q, %v", tt.query, table, condition, ok, tt.wantTable, tt.wantCondition, tt.wantOK)
+		}
+	}
+}
@@ -5,15 +5,48 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"regexp"
 	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattermost/morph/sources"
 
 	"github.com/mattermost/migration-assist/internal/logger"
 )
 
+const (
+	// nonTransactionalPrefix marks the morph migrations that can't be run in a transaction.
+	nonTransactionalPrefix = "-- morph:nontransactional"
+)
+
 var (
 	ignoredTablesForEmptyCheck = map[string]bool{"db_migrations": true, "systems": true, "config_migrations": true, "db_lock": true}
+
+	concurrentlyRegex = regexp.MustCompile(`(?i)\b((?:CREATE\s+(?:UNIQUE\s+)?|DROP\s+)INDEX)\s+CONCURRENTLY\b`)
 )
 
+// MigrationError reports the migration and the statement that failed while
+// running the migrations in a transaction.
+type MigrationError struct {
+	Version   uint32
+	Name      string
+	Statement string
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	if e.Statement == "" {
+		return fmt.Sprintf("migration %d (%s) failed: %s", e.Version, e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("migration %d (%s) failed: %s\nfailed statement:\n%s", e.Version, e.Name, e.Err, e.Statement)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
 func openPostgres(dataSource string) (*DB, error) {
 	db, err := sql.Open("postgres", dataSource)
 	if err != nil {
@@ -47,30 +80,23 @@ func extractPostgresDatabaseNameFromURL(conn string) (string, error) {
 	return uri.Path[1:], nil
 }
 
+// CheckPostgresDefaultSchema ensures that the schema is in the search_path of
+// the current session. If it is not, the search_path of the session is set to
+// include the schema.
 func (db *DB) CheckPostgresDefaultSchema(ctx context.Context, schema string, logger logger.LogInterface) error {
-	rows, err := db.db.QueryContext(ctx, "SHOW search_path")
+	var searchPath string
+	err := db.conn.QueryRowContext(ctx, "SHOW search_path").Scan(&searchPath)
 	if err != nil {
 		return fmt.Errorf("could not determine the search_path: %w", err)
 	}
-	defer rows.Close()
 
-	var schemas []string
-	for rows.Next() {
-		var s string
-		err := rows.Scan(&s)
-		if err != nil {
-			return fmt.Errorf("could not scan the schema for search_path: %w", err)
-		}
-		schemas = append(schemas, s)
-	}
-	slices.Sort(schemas)
-
-	schemaSetting := fmt.Sprintf("%q, %s", "$user", schema)
+	schemas := ParseSearchPath(searchPath)
 	if len(schemas) == 0 {
 		return fmt.Errorf("no value available for search_path")
-	} else if _, ok := slices.BinarySearch(schemas, schemaSetting); !ok {
+	} else if !slices.Contains(schemas, schema) {
 		logger.Printf("could not find the default schema %q in search_path, consider setting it from the postgresql console\n", schema)
-		err := db.ExecQuery(ctx, fmt.Sprintf("SELECT pg_catalog.set_config('search_path', '%s', false)", schemaSetting))
+		schemaSetting := fmt.Sprintf("%q, %s", "$user", pq.QuoteIdentifier(schema))
+		_, err = db.conn.ExecContext(ctx, "SELECT pg_catalog.set_config('search_path', $1, false)", schemaSetting)
 		if err != nil {
 			return fmt.Errorf("could not set search_path for the session: %w", err)
 		}
@@ -80,6 +106,43 @@ func (db *DB) CheckPostgresDefaultSchema(ctx context.Context, schema string, log
 	return nil
 }
 
+// ParseSearchPath splits the value of the search_path setting into schema
+// names. Quoted names are unquoted, "$user" is kept as is.
+func ParseSearchPath(searchPath string) []string {
+	var schemas []string
+	for _, s := range strings.Split(searchPath, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if s != `"$user"` && len(s) > 1 && s[0] == '"' && s[len(s)-1] == '"' {
+			s = strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+		}
+		schemas = append(schemas, s)
+	}
+
+	return schemas
+}
+
+// WithPostgresSearchPath adds the search_path run-time parameter to the DSN so
+// that every connection opened with it uses the given schema. The DSN is
+// returned as is if it already sets a search_path.
+func WithPostgresSearchPath(dsn, schema string) (string, error) {
+	uri, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("could not parse DSN: %w", err)
+	}
+
+	q := uri.Query()
+	if q.Has("search_path") {
+		return dsn, nil
+	}
+	q.Set("search_path", pq.QuoteIdentifier(schema))
+	uri.RawQuery = q.Encode()
+
+	return uri.String(), nil
+}
+
 func (db *DB) CheckPostgresSchemaOwnership(ctx context.Context, schema, user string) error {
 	cnt, err := db.RunSelectCountQuery(ctx, fmt.Sprintf(`SELECT COUNT(*)
 		FROM information_schema.schemata
@@ -96,9 +159,9 @@ func (db *DB) CheckPostgresSchemaOwnership(ctx context.Context, schema, user str
 	return nil
 }
 
-func (db *DB) CheckIfPostgresTablesEmpty(ctx context.Context) ([]string, error) {
+func (db *DB) CheckIfPostgresTablesEmpty(ctx context.Context, schema string) ([]string, error) {
 	var tables []string
-	rows, err := db.db.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'")
+	rows, err := db.db.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = $1", schema)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch tables from the database: %w", err)
 	}
@@ -119,7 +182,7 @@ func (db *DB) CheckIfPostgresTablesEmpty(ctx context.Context) ([]string, error)
 	tablesWithData := []string{}
 	for _, table := range tables {
 		var count int
-		err := db.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+		err := db.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table))).Scan(&count)
 		if err != nil {
 			return nil, fmt.Errorf("could not fetch count from the table %s: %w", table, err)
 		}
@@ -131,3 +194,91 @@ func (db *DB) CheckIfPostgresTablesEmpty(ctx context.Context) ([]string, error)
 
 	return tablesWithData, nil
 }
+
+// RunMigrationsInTransaction applies the pending migrations of the source
+// within a single transaction, so that a failure leaves the database as it was
+// before. The migrations that can't run in a transaction are applied without
+// the CONCURRENTLY keyword, which is fine as the tables are empty at this stage.
+// The mutex of morph is held while the migrations are applied. The number of
+// applied migrations is returned.
+func (db *DB) RunMigrationsInTransaction(ctx context.Context, src sources.Source, logger logger.LogInterface) (int, error) {
+	if db.dbType != "postgres" {
+		return 0, fmt.Errorf("running migrations in a transaction is not supported for %s", db.dbType)
+	}
+
+	// the same mutex as morph is held, so that a concurrent run does not
+	// apply the same migrations.
+	unlock, err := db.lockMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	pending, err := db.PlanMigrations(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		// no-op if the transaction is committed
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version bigint not null primary key, name varchar not null)", migrationsTable))
+	if err != nil {
+		return 0, fmt.Errorf("could not create migrations table: %w", err)
+	}
+
+	for _, m := range pending {
+		query := m.Query()
+		if strings.HasPrefix(query, nonTransactionalPrefix) {
+			logger.Printf("%s is not transactional, applying it without CONCURRENTLY\n", m.Name)
+			query = concurrentlyRegex.ReplaceAllString(query, "$1")
+		}
+
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT migration"); err != nil {
+			return 0, fmt.Errorf("could not create savepoint: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			migrationErr := &MigrationError{
+				Version: m.Version,
+				Name:    m.Name,
+				Err:     err,
+			}
+			if _, err2 := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT migration"); err2 == nil {
+				migrationErr.Statement = findFailingStatement(ctx, tx, query)
+			}
+
+			return 0, migrationErr
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", migrationsTable), m.Version, m.Name)
+		if err != nil {
+			return 0, fmt.Errorf("could not save version of %s: %w", m.Name, err)
+		}
+		logger.Printf("applied %s\n", m.Name)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return len(pending), nil
+}
+
+// findFailingStatement runs the statements of the query one by one and returns
+// the first one that fails. The transaction is aborted afterwards.
+func findFailingStatement(ctx context.Context, tx *sql.Tx, query string) string {
+	for _, stmt := range SplitStatements(query) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return stmt
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,693 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// minPostgresVersion is the minimum major version of Postgres supported by Mattermost.
+	minPostgresVersion = 13
+	// maxTestedPostgresVersion is the latest major version of Postgres Mattermost is tested against.
+	maxTestedPostgresVersion = 17
+	// defaultLockWaitTimeout is the default value of innodb_lock_wait_timeout.
+	defaultLockWaitTimeout = 50
+)
+
+var (
+	// fixStatementRegexes map the statements of the fix queries to the
+	// privileges they require on the table they modify. The unicode fixes
+	// update the table passed to the procedure.
+	fixStatementRegexes = map[string]*regexp.Regexp{
+		"DELETE": regexp.MustCompile("(?i)DELETE\\s+FROM\\s+`?(\\w+)`?"),
+		"UPDATE": regexp.MustCompile("(?i)(?:\\bUPDATE\\s+`?(\\w+)`?\\s+SET|CALL\\s+\\w+\\s*\\(\\s*'(\\w+)')"),
+		"ALTER":  regexp.MustCompile("(?i)ALTER\\s+TABLE\\s+`?(\\w+)`?"),
+		"DROP":   regexp.MustCompile("(?i)DROP\\s+TABLE\\s+(?:IF\\s+EXISTS\\s+)?`?(\\w+)`?"),
+	}
+)
+
+type PreflightStatus string
+
+const (
+	PreflightPass PreflightStatus = "pass"
+	PreflightWarn PreflightStatus = "warn"
+	PreflightFail PreflightStatus = "fail"
+)
+
+// PreflightResult is the outcome of a single preflight check.
+type PreflightResult struct {
+	Name    string
+	Status  PreflightStatus
+	Message string
+}
+
+type PostgresPreflightOptions struct {
+	Schema string
+	User   string
+	// Connections is the number of connections the load is expected to open.
+	Connections int
+	// RequiredSpace is the disk space the migration is expected to use, the
+	// disk space is not checked if it is zero.
+	RequiredSpace int64
+	// RequiredSpaceErr is the error of the estimation of RequiredSpace, which
+	// is reported as a warning.
+	RequiredSpaceErr error
+	// FreeSpace is the free disk space of the target supplied by the user. If
+	// it is zero and DetectFreeSpace is set, the free space of the data
+	// directory of the server is read from the local file system instead.
+	FreeSpace       int64
+	DetectFreeSpace bool
+}
+
+// RunPostgresPreflight checks whether the Postgres database is suitable to be
+// the target of the migration. Every check reports its own result, an error is
+// only returned if a check could not be run at all.
+func (db *DB) RunPostgresPreflight(ctx context.Context, opts PostgresPreflightOptions) ([]PreflightResult, error) {
+	checks := []func(context.Context, PostgresPreflightOptions) (PreflightResult, error){
+		db.checkPostgresVersion,
+		db.checkPostgresEncoding,
+		db.checkPostgresLocale,
+		db.checkPostgresSchemaPrivileges,
+		db.checkPostgresDatabasePrivileges,
+		db.checkPostgresAlterUser,
+		db.checkPostgresConnections,
+		db.checkPostgresDiskSpace,
+	}
+
+	results := make([]PreflightResult, 0, len(checks))
+	for _, check := range checks {
+		res, err := check(ctx, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (db *DB) checkPostgresVersion(ctx context.Context, _ PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "server version"}
+
+	var versionNum int
+	var version string
+	err := db.conn.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int, current_setting('server_version')").Scan(&versionNum, &version)
+	if err != nil {
+		return res, fmt.Errorf("could not get server version: %w", err)
+	}
+
+	major := versionNum / 10000
+	switch {
+	case major < minPostgresVersion:
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("Postgres %s is not supported, at least Postgres %d is required", version, minPostgresVersion)
+	case major > maxTestedPostgresVersion:
+		res.Status = PreflightWarn
+		res.Message = fmt.Sprintf("Postgres %s is newer than the latest tested version (%d)", version, maxTestedPostgresVersion)
+	default:
+		res.Status = PreflightPass
+		res.Message = fmt.Sprintf("Postgres %s", version)
+	}
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresEncoding(ctx context.Context, _ PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "database encoding"}
+
+	var encoding string
+	err := db.conn.QueryRowContext(ctx, "SELECT pg_encoding_to_char(encoding) FROM pg_database WHERE datname = current_database()").Scan(&encoding)
+	if err != nil {
+		return res, fmt.Errorf("could not get database encoding: %w", err)
+	}
+
+	if encoding != "UTF8" {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("database encoding is %s, the database should be created with ENCODING 'UTF8'", encoding)
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = encoding
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresLocale(ctx context.Context, _ PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "database locale"}
+
+	var collate, ctype string
+	err := db.conn.QueryRowContext(ctx, "SELECT datcollate, datctype FROM pg_database WHERE datname = current_database()").Scan(&collate, &ctype)
+	if err != nil {
+		return res, fmt.Errorf("could not get database locale: %w", err)
+	}
+
+	res.Message = fmt.Sprintf("lc_collate: %s, lc_ctype: %s", collate, ctype)
+	if !isUTF8Locale(collate) || !isUTF8Locale(ctype) {
+		res.Status = PreflightWarn
+		res.Message += ", a UTF-8 locale is recommended for sorting and case conversion of non-ASCII text"
+		return res, nil
+	}
+	res.Status = PreflightPass
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresSchemaPrivileges(ctx context.Context, opts PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "schema privileges"}
+
+	var exists bool
+	err := db.conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", opts.Schema).Scan(&exists)
+	if err != nil {
+		return res, fmt.Errorf("could not check schema: %w", err)
+	}
+	if !exists {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("schema %q does not exist", opts.Schema)
+		return res, nil
+	}
+
+	var canCreate bool
+	err = db.conn.QueryRowContext(ctx, "SELECT has_schema_privilege(current_user, $1, 'CREATE')", opts.Schema).Scan(&canCreate)
+	if err != nil {
+		return res, fmt.Errorf("could not check schema privileges: %w", err)
+	}
+
+	if !canCreate {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("the user is missing the CREATE privilege on the %q schema, run: GRANT CREATE ON SCHEMA %s TO %s", opts.Schema, opts.Schema, opts.User)
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = fmt.Sprintf("CREATE on %q", opts.Schema)
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresDatabasePrivileges(ctx context.Context, opts PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "database privileges"}
+
+	// pgloader renames the target schema before and after the load
+	var canCreate bool
+	err := db.conn.QueryRowContext(ctx, "SELECT has_database_privilege(current_user, current_database(), 'CREATE')").Scan(&canCreate)
+	if err != nil {
+		return res, fmt.Errorf("could not check database privileges: %w", err)
+	}
+
+	if !canCreate {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("the user is missing the CREATE privilege on the database which is required to rename the schema during the load, run: GRANT CREATE ON DATABASE %s TO %s", db.databaseName, opts.User)
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = "CREATE on the database"
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresAlterUser(ctx context.Context, opts PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "alter user"}
+
+	// the search_path of the user is altered after the load. Roles can always
+	// alter their own settings, otherwise superuser or CREATEROLE is required.
+	var currentUser string
+	var superuser, createRole bool
+	err := db.conn.QueryRowContext(ctx, "SELECT rolname, rolsuper, rolcreaterole FROM pg_roles WHERE rolname = current_user").Scan(&currentUser, &superuser, &createRole)
+	if err != nil {
+		return res, fmt.Errorf("could not check role attributes: %w", err)
+	}
+
+	if currentUser != opts.User && !superuser && !createRole {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("%q can not alter the search_path of %q, connect as %q or grant CREATEROLE", currentUser, opts.User, opts.User)
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = fmt.Sprintf("%q can alter the search_path of %q", currentUser, opts.User)
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresConnections(ctx context.Context, opts PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "max connections"}
+
+	var maxConnections, reserved, used int
+	err := db.conn.QueryRowContext(ctx, `SELECT current_setting('max_connections')::int,
+		current_setting('superuser_reserved_connections')::int,
+		(SELECT COUNT(*) FROM pg_stat_activity)`).Scan(&maxConnections, &reserved, &used)
+	if err != nil {
+		return res, fmt.Errorf("could not check connections: %w", err)
+	}
+
+	available := maxConnections - reserved - used
+	res.Message = fmt.Sprintf("%d of %d connections available, the load requires %d", available, maxConnections, opts.Connections)
+	if available < opts.Connections {
+		res.Status = PreflightWarn
+		res.Message += ", consider increasing max_connections or lowering the workers in the pgloader configuration"
+		return res, nil
+	}
+	res.Status = PreflightPass
+
+	return res, nil
+}
+
+func (db *DB) checkPostgresDiskSpace(ctx context.Context, opts PostgresPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "disk space"}
+
+	if opts.RequiredSpaceErr != nil {
+		res.Status = PreflightWarn
+		res.Message = fmt.Sprintf("could not estimate the required disk space: %s", opts.RequiredSpaceErr)
+		return res, nil
+	}
+
+	if opts.RequiredSpace == 0 {
+		res.Status = PreflightWarn
+		res.Message = "the size of the source database is not known, supply --mysql or --applied-migrations to check the disk space"
+		return res, nil
+	}
+
+	free, measured := opts.FreeSpace, "supplied"
+	if free == 0 && opts.DetectFreeSpace {
+		dir, err := db.postgresDataDirectory(ctx)
+		if err != nil {
+			res.Status = PreflightWarn
+			res.Message = fmt.Sprintf("could not determine the data directory, %.1f GiB is required: %s", gib(opts.RequiredSpace), err)
+			return res, nil
+		}
+
+		free, err = freeDiskSpace(dir)
+		if err != nil {
+			res.Status = PreflightWarn
+			res.Message = fmt.Sprintf("could not determine the free space of %s, %.1f GiB is required: %s", dir, gib(opts.RequiredSpace), err)
+			return res, nil
+		}
+		measured = "measured on " + dir
+	}
+
+	if free == 0 {
+		res.Status = PreflightWarn
+		res.Message = fmt.Sprintf("the free space of the target is not known, %.1f GiB is required, supply --target-free-space or --detect-free-space to check it", gib(opts.RequiredSpace))
+		return res, nil
+	}
+
+	res.Message = fmt.Sprintf("%.1f GiB free (%s), the migration requires %.1f GiB", gib(free), measured, gib(opts.RequiredSpace))
+	if free < opts.RequiredSpace {
+		res.Status = PreflightWarn
+		res.Message += ", the volume may fill up during the load or the index creation"
+		return res, nil
+	}
+	res.Status = PreflightPass
+
+	return res, nil
+}
+
+// postgresDataDirectory returns the directory of the tablespace of the current
+// database. Reading the data directory requires the superuser or the
+// pg_read_all_settings role.
+func (db *DB) postgresDataDirectory(ctx context.Context) (string, error) {
+	var dir string
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(NULLIF(pg_tablespace_location(t.oid), ''), current_setting('data_directory'))
+		FROM pg_database d JOIN pg_tablespace t ON t.oid = d.dattablespace
+		WHERE d.datname = current_database()`).Scan(&dir)
+	if err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func gib(n int64) float64 {
+	return float64(n) / (1 << 30)
+}
+
+func isUTF8Locale(locale string) bool {
+	l := strings.ToLower(locale)
+	return l == "c" || l == "posix" || strings.Contains(l, "utf-8") || strings.Contains(l, "utf8")
+}
+
+// TablePrivilege is a privilege required on a table.
+type TablePrivilege struct {
+	Privilege string
+	Table     string
+}
+
+type MySQLPreflightOptions struct {
+	// Fixes are the privileges required by the fixes that are going to be run.
+	Fixes []TablePrivilege
+	// NoProcedures is set if the checks are run without stored procedures.
+	NoProcedures bool
+}
+
+// RequiredFixPrivileges parses the fix queries of the given categories and
+// returns the privileges they require on the tables they modify.
+func RequiredFixPrivileges(assets fs.FS, categories ...string) ([]TablePrivilege, error) {
+	var privileges []TablePrivilege
+	for _, category := range categories {
+		dir := path.Join("fixes", category)
+		entries, err := fs.ReadDir(assets, dir)
+		if err != nil {
+			return nil, fmt.Errorf("could not read fixes: %w", err)
+		}
+
+		for _, e := range entries {
+			b, err2 := fs.ReadFile(assets, path.Join(dir, e.Name()))
+			if err2 != nil {
+				return nil, fmt.Errorf("could not read fix: %w", err2)
+			}
+
+			for privilege, re := range fixStatementRegexes {
+				for _, m := range re.FindAllStringSubmatch(string(b), -1) {
+					table := slices.Max(m[1:])
+					p := TablePrivilege{Privilege: privilege, Table: table}
+					if !slices.Contains(privileges, p) {
+						privileges = append(privileges, p)
+					}
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(privileges, func(a, b TablePrivilege) int {
+		if c := strings.Compare(a.Table, b.Table); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Privilege, b.Privilege)
+	})
+
+	return privileges, nil
+}
+
+// RunMySQLPreflight checks whether the MySQL database can be checked and fixed
+// with the privileges and settings of the current session. Every check reports
+// its own result, an error is only returned if a check could not be run at all.
+func (db *DB) RunMySQLPreflight(ctx context.Context, opts MySQLPreflightOptions) ([]PreflightResult, error) {
+	checks := []func(context.Context, MySQLPreflightOptions) (PreflightResult, error){
+		db.checkMySQLVisibleTables,
+		db.checkMySQLRoutinePrivileges,
+		db.checkMySQLFixPrivileges,
+		db.checkMySQLSQLMode,
+		db.checkMySQLCollations,
+		db.checkMySQLLockWaitTimeout,
+	}
+
+	results := make([]PreflightResult, 0, len(checks))
+	for _, check := range checks {
+		res, err := check(ctx, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (db *DB) checkMySQLVisibleTables(ctx context.Context, _ MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "table access"}
+
+	// information_schema only lists the tables the user has a privilege on
+	var tables int
+	err := db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'").Scan(&tables)
+	if err != nil {
+		return res, fmt.Errorf("could not list tables: %w", err)
+	}
+	if tables == 0 {
+		res.Status = PreflightFail
+		res.Message = fmt.Sprintf("no tables are visible in %q, run: GRANT SELECT ON %s.* TO %s", db.databaseName, db.databaseName, db.mysqlGrantee(ctx))
+		return res, nil
+	}
+
+	missing, err := db.missingMySQLPrivileges(ctx, []TablePrivilege{{Privilege: "SELECT"}})
+	if err != nil {
+		return res, err
+	}
+	if len(missing) > 0 {
+		// the privilege may still be granted on the tables one by one
+		rows, err2 := db.conn.QueryContext(ctx, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'")
+		if err2 != nil {
+			return res, fmt.Errorf("could not list tables: %w", err2)
+		}
+		var required []TablePrivilege
+		for rows.Next() {
+			var table string
+			if err2 = rows.Scan(&table); err2 != nil {
+				rows.Close()
+				return res, fmt.Errorf("could not scan table: %w", err2)
+			}
+			required = append(required, TablePrivilege{Privilege: "SELECT", Table: table})
+		}
+		rows.Close()
+		if err2 = rows.Err(); err2 != nil {
+			return res, fmt.Errorf("could not list tables: %w", err2)
+		}
+
+		missing, err = db.missingMySQLPrivileges(ctx, required)
+		if err != nil {
+			return res, err
+		}
+	}
+	selectable := tables - len(missing)
+
+	res.Message = fmt.Sprintf("SELECT on %d of %d tables", selectable, tables)
+	if len(missing) > 0 {
+		res.Status = db.missingPrivilegeStatus(ctx)
+		res.Message += fmt.Sprintf(", run: GRANT SELECT ON %s.* TO %s", db.databaseName, db.mysqlGrantee(ctx))
+		return res, nil
+	}
+	res.Status = PreflightPass
+
+	return res, nil
+}
+
+func (db *DB) checkMySQLRoutinePrivileges(ctx context.Context, opts MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "routine privileges"}
+
+	if opts.NoProcedures {
+		res.Status = PreflightPass
+		res.Message = "not required without procedures"
+		return res, nil
+	}
+
+	missing, err := db.missingMySQLPrivileges(ctx, []TablePrivilege{{Privilege: "CREATE ROUTINE"}})
+	if err != nil {
+		return res, err
+	}
+
+	if len(missing) > 0 {
+		res.Status = db.missingPrivilegeStatus(ctx)
+		res.Message = fmt.Sprintf("the checks are run with stored procedures, run: GRANT CREATE ROUTINE ON %s.* TO %s or use --no-procedures", db.databaseName, db.mysqlGrantee(ctx))
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = "CREATE ROUTINE"
+
+	return res, nil
+}
+
+func (db *DB) checkMySQLFixPrivileges(ctx context.Context, opts MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "fix privileges"}
+
+	if len(opts.Fixes) == 0 {
+		res.Status = PreflightPass
+		res.Message = "no fixes requested"
+		return res, nil
+	}
+
+	// some of the fixes are for the tables of the plugins, which may not exist
+	var required []TablePrivilege
+	for _, p := range opts.Fixes {
+		exists, err := db.tableExists(ctx, p.Table)
+		if err != nil {
+			return res, fmt.Errorf("could not check table %s: %w", p.Table, err)
+		}
+		if exists {
+			required = append(required, p)
+		}
+	}
+
+	missing, err := db.missingMySQLPrivileges(ctx, required)
+	if err != nil {
+		return res, err
+	}
+
+	if len(missing) > 0 {
+		res.Status = db.missingPrivilegeStatus(ctx)
+		grantee := db.mysqlGrantee(ctx)
+		grants := make([]string, 0, len(missing))
+		for _, p := range missing {
+			grants = append(grants, fmt.Sprintf("GRANT %s ON %s.%s TO %s;", p.Privilege, db.databaseName, p.Table, grantee))
+		}
+		res.Message = "the requested fixes require the following privileges, run: " + strings.Join(grants, " ")
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = fmt.Sprintf("%d privilege(s) required by the fixes", len(required))
+
+	return res, nil
+}
+
+func (db *DB) checkMySQLSQLMode(ctx context.Context, _ MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "sql_mode"}
+
+	var mode string
+	if err := db.conn.QueryRowContext(ctx, "SELECT @@SESSION.sql_mode").Scan(&mode); err != nil {
+		return res, fmt.Errorf("could not get sql_mode: %w", err)
+	}
+
+	res.Message = mode
+	modes := strings.Split(mode, ",")
+	switch {
+	case slices.Contains(modes, "NO_BACKSLASH_ESCAPES"):
+		// the unicode checks and fixes match escape sequences with backslashes
+		res.Status = PreflightFail
+		res.Message += ", NO_BACKSLASH_ESCAPES breaks the unicode checks, remove it from sql_mode"
+	case slices.Contains(modes, "ANSI_QUOTES"):
+		res.Status = PreflightWarn
+		res.Message += ", ANSI_QUOTES changes how quoted strings are interpreted and is not used by Mattermost"
+	default:
+		res.Status = PreflightPass
+	}
+
+	return res, nil
+}
+
+func (db *DB) checkMySQLCollations(ctx context.Context, _ MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "table collations"}
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT TABLE_NAME, TABLE_COLLATION FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+		AND TABLE_COLLATION NOT LIKE 'utf8mb4%' ORDER BY TABLE_NAME`)
+	if err != nil {
+		return res, fmt.Errorf("could not get table collations: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table, collation string
+		if err = rows.Scan(&table, &collation); err != nil {
+			return res, fmt.Errorf("could not scan table collation: %w", err)
+		}
+		tables = append(tables, fmt.Sprintf("%s (%s)", table, collation))
+	}
+	if err = rows.Err(); err != nil {
+		return res, fmt.Errorf("could not get table collations: %w", err)
+	}
+
+	if len(tables) > 0 {
+		res.Status = PreflightWarn
+		res.Message = fmt.Sprintf("tables not using utf8mb4: %s, characters outside of the character set may already be lost", strings.Join(tables, ", "))
+		return res, nil
+	}
+
+	res.Status = PreflightPass
+	res.Message = "all tables use utf8mb4"
+
+	return res, nil
+}
+
+func (db *DB) checkMySQLLockWaitTimeout(ctx context.Context, opts MySQLPreflightOptions) (PreflightResult, error) {
+	res := PreflightResult{Name: "innodb_lock_wait_timeout"}
+
+	var timeout int
+	if err := db.conn.QueryRowContext(ctx, "SELECT @@SESSION.innodb_lock_wait_timeout").Scan(&timeout); err != nil {
+		return res, fmt.Errorf("could not get innodb_lock_wait_timeout: %w", err)
+	}
+
+	res.Message = strconv.Itoa(timeout) + "s"
+	if len(opts.Fixes) > 0 && timeout < defaultLockWaitTimeout {
+		res.Status = PreflightWarn
+		res.Message += fmt.Sprintf(", the fixes may time out on busy tables, consider raising it to at least %ds or stopping the Mattermost server", defaultLockWaitTimeout)
+		return res, nil
+	}
+	res.Status = PreflightPass
+
+	return res, nil
+}
+
+// missingMySQLPrivileges returns the privileges that are not granted to the
+// current user either globally, on the database or on the table. Privileges
+// with an empty table are only looked up globally and on the database.
+func (db *DB) missingMySQLPrivileges(ctx context.Context, privileges []TablePrivilege) ([]TablePrivilege, error) {
+	var currentUser string
+	if err := db.conn.QueryRowContext(ctx, "SELECT CURRENT_USER()").Scan(&currentUser); err != nil {
+		return nil, fmt.Errorf("could not get current user: %w", err)
+	}
+
+	idx := strings.LastIndex(currentUser, "@")
+	if idx < 0 {
+		return nil, fmt.Errorf("could not parse current user %q", currentUser)
+	}
+	grantee := fmt.Sprintf("'%s'@'%s'", currentUser[:idx], currentUser[idx+1:])
+
+	var missing []TablePrivilege
+	for _, p := range privileges {
+		var granted bool
+		err := db.conn.QueryRowContext(ctx, `SELECT
+			EXISTS (SELECT 1 FROM information_schema.USER_PRIVILEGES WHERE GRANTEE = ? AND PRIVILEGE_TYPE = ?)
+			OR EXISTS (SELECT 1 FROM information_schema.SCHEMA_PRIVILEGES WHERE GRANTEE = ? AND DATABASE() LIKE TABLE_SCHEMA AND PRIVILEGE_TYPE = ?)
+			OR EXISTS (SELECT 1 FROM information_schema.TABLE_PRIVILEGES WHERE GRANTEE = ? AND TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PRIVILEGE_TYPE = ?)`,
+			grantee, p.Privilege, grantee, p.Privilege, grantee, p.Table, p.Privilege).Scan(&granted)
+		if err != nil {
+			return nil, fmt.Errorf("could not check privileges: %w", err)
+		}
+
+		if !granted {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing, nil
+}
+
+// missingPrivilegeStatus returns the status for a privilege that could not be
+// found. Privileges granted through roles are not listed by information_schema,
+// hence only a warning is reported if the user has any roles active.
+// mysqlGrantee returns the account of the session as it is written in a GRANT
+// statement, falling back to a placeholder if it can't be read.
+func (db *DB) mysqlGrantee(ctx context.Context) string {
+	var account string
+	err := db.conn.QueryRowContext(ctx, "SELECT CURRENT_USER()").Scan(&account)
+	if err != nil {
+		return "<user>"
+	}
+
+	return formatGrantee(account)
+}
+
+// formatGrantee quotes an account returned by CURRENT_USER(), e.g. mmuser@%
+// becomes 'mmuser'@'%'.
+func formatGrantee(account string) string {
+	i := strings.LastIndex(account, "@")
+	if i < 0 {
+		return fmt.Sprintf("'%s'", account)
+	}
+
+	return fmt.Sprintf("'%s'@'%s'", account[:i], account[i+1:])
+}
+
+func (db *DB) missingPrivilegeStatus(ctx context.Context) PreflightStatus {
+	var role string
+	err := db.conn.QueryRowContext(ctx, "SELECT CURRENT_ROLE()").Scan(&role)
+	if err != nil {
+		// CURRENT_ROLE is not available before MySQL 8.0, hence no roles
+		return PreflightFail
+	}
+
+	if role != "" && role != "NONE" {
+		return PreflightWarn
+	}
+
+	return PreflightFail
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRequiredFixPrivileges(t *testing.T) {
+	assets := fstest.MapFS{
+		"fixes/orphans/fix_posts.channelid.sql":     {Data: []byte("DELETE FROM Posts WHERE NOT EXISTS (SELECT 1 FROM Channels p WHERE p.Id = Posts.ChannelId);")},
+		"fixes/orphans/fix_reactions.postid.sql":    {Data: []byte("DELETE FROM Reactions WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = Reactions.PostId);")},
+		"fixes/unicode/fix_users_props.sql":         {Data: []byte("CALL CleanUnicodeEscapes('Users', 'Props');")},
+		"fixes/artifacts/fix_threads.teamid.sql":    {Data: []byte("SET @s = 'ALTER TABLE Threads DROP COLUMN TeamId;';")},
+		"fixes/artifacts/fix_schema_migrations.sql": {Data: []byte("SET @s = 'DROP TABLE schema_migrations;';")},
+		"fixes/artifacts/fix_sessions.props.sql":    {Data: []byte("UPDATE `Sessions` SET Props = '{}';")},
+		"fixes/json/fix_jobs.data.sql":              {Data: []byte("CALL ReplaceInvalidJSON('Jobs', 'Data', '{}');")},
+	}
+
+	got, err := RequiredFixPrivileges(assets, "artifacts", "unicode", "orphans")
+	if err != nil {
+		t.Fatalf("RequiredFixPrivileges() error = %v, want no error", err)
+	}
+
+	want := []TablePrivilege{
+		{Privilege: "DELETE", Table: "Posts"},
+		{Privilege: "DELETE", Table: "Reactions"},
+		{Privilege: "UPDATE", Table: "Sessions"},
+		{Privilege: "ALTER", Table: "Threads"},
+		{Privilege: "UPDATE", Table: "Users"},
+		{Privilege: "DROP", Table: "schema_migrations"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("RequiredFixPrivileges() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckPostgresDiskSpaceWithoutSizes(t *testing.T) {
+	db := &DB{dbType: "postgres"}
+
+	tests := []struct {
+		name string
+		opts PostgresPreflightOptions
+	}{
+		{name: "unknown size", opts: PostgresPreflightOptions{FreeSpace: 1 << 40}},
+		{name: "estimate error", opts: PostgresPreflightOptions{RequiredSpaceErr: errors.New("could not connect")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := db.checkPostgresDiskSpace(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("checkPostgresDiskSpace() error = %v, want no error", err)
+			}
+			if res.Status != PreflightWarn {
+				t.Errorf("checkPostgresDiskSpace() status = %v, want %v", res.Status, PreflightWarn)
+			}
+		})
+	}
+}
+
+func TestFormatGrantee(t *testing.T) {
+	tests := []struct {
+		account  string
+		expected string
+	}{
+		{"mmuser@%", "'mmuser'@'%'"},
+		{"mmuser@localhost", "'mmuser'@'localhost'"},
+		{"mm@user@10.0.0.1", "'mm@user'@'10.0.0.1'"},
+		{"mmuser", "'mmuser'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.account, func(t *testing.T) {
+			if got := formatGrantee(test.account); got != test.expected {
+				t.Errorf("formatGrantee() = %s, want %s", got, test.expected)
+			}
+		})
+	}
+}
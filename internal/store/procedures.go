@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxUnicodeFixIterations limits the number of passes over a column to
+	// remove escapes preceded by multiple backslashes, same as the procedure.
+	maxUnicodeFixIterations = 5
+)
+
+var (
+	procedureCallRegex = regexp.MustCompile(`(?is)^\s*CALL\s+(\w+)\s*\((.*)\)\s*;?\s*$`)
+	identifierRegex    = regexp.MustCompile(`^\w+$`)
+)
+
+// ProcedureCall is a call to one of the procedures used by the checks and the
+// fixes, e.g. CALL CheckInvalidJSON('Jobs', 'Data').
+type ProcedureCall struct {
+	Name string
+	Args []string
+}
+
+// ParseProcedureCall parses the query if it consists of a single procedure
+// call. False is returned otherwise.
+func ParseProcedureCall(query string) (ProcedureCall, bool) {
+	m := procedureCallRegex.FindStringSubmatch(query)
+	if m == nil {
+		return ProcedureCall{}, false
+	}
+
+	call := ProcedureCall{Name: m[1]}
+	for _, arg := range strings.Split(m[2], ",") {
+		arg = strings.TrimSpace(arg)
+		arg = strings.Trim(arg, `'"`)
+		call.Args = append(call.Args, arg)
+	}
+
+	return call, true
+}
+
+// RunSelectCountQueryWithoutProcedures is the same as RunSelectCountQuery
+// except that the calls to the check procedures are run as plain queries, so
+// that the checks can be run without creating the procedures.
+func (db *DB) RunSelectCountQueryWithoutProcedures(ctx context.Context, query string) (int, error) {
+	call, ok := ParseProcedureCall(query)
+	if !ok {
+		return db.RunSelectCountQuery(ctx, query)
+	}
+
+	switch call.Name {
+	case "CheckUnsupportedUnicode":
+		if err := call.validate(2); err != nil {
+			return 0, err
+		}
+
+		return db.countIfColumnExists(ctx, call.Args[0], call.Args[1],
+			fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `%s` LIKE '%%\\u0000%%'", call.Args[0], call.Args[1]))
+	case "CheckInvalidJSON":
+		if err := call.validate(2); err != nil {
+			return 0, err
+		}
+
+		return db.countIfColumnExists(ctx, call.Args[0], call.Args[1],
+			fmt.Sprintf("SELECT COUNT(*) FROM `%[1]s` WHERE `%[2]s` IS NOT NULL AND JSON_VALID(`%[2]s`) = 0", call.Args[0], call.Args[1]))
+	default:
+		return 0, fmt.Errorf("procedure %s is not supported without procedures", call.Name)
+	}
+}
+
+// ExecQueryWithoutProcedures is the same as ExecQuery except that the calls to
+// the fix procedures are run as plain queries.
+func (db *DB) ExecQueryWithoutProcedures(ctx context.Context, query string) error {
+	call, ok := ParseProcedureCall(query)
+	if !ok {
+		return db.ExecQuery(ctx, query)
+	}
+
+	switch call.Name {
+	case "CleanUnicodeEscapes":
+		if err := call.validate(2); err != nil {
+			return err
+		}
+
+		q := fmt.Sprintf("UPDATE `%[1]s` SET `%[2]s` = REGEXP_REPLACE(`%[2]s`, '\\\\\\\\+u0000', '') WHERE `%[2]s` REGEXP '\\\\\\\\+u0000'", call.Args[0], call.Args[1])
+		for i := 0; i < maxUnicodeFixIterations; i++ {
+			res, err := db.conn.ExecContext(ctx, q)
+			if err != nil {
+				return err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+		}
+
+		return nil
+	case "ReplaceInvalidJSON":
+		if err := call.validate(3); err != nil {
+			return err
+		}
+
+		exists, err := db.columnExists(ctx, call.Args[0], call.Args[1])
+		if err != nil || !exists {
+			return err
+		}
+
+		_, err = db.conn.ExecContext(ctx, fmt.Sprintf("UPDATE `%[1]s` SET `%[2]s` = ? WHERE `%[2]s` IS NOT NULL AND JSON_VALID(`%[2]s`) = 0", call.Args[0], call.Args[1]), call.Args[2])
+		return err
+	default:
+		return fmt.Errorf("procedure %s is not supported without procedures", call.Name)
+	}
+}
+
+// InvalidJSONSamples returns the primary keys of a few rows having invalid
+// JSON values in the column checked by the given CheckInvalidJSON call.
+func (db *DB) InvalidJSONSamples(ctx context.Context, query string) ([]string, error) {
+	call, ok := ParseProcedureCall(query)
+	if !ok || call.Name != "CheckInvalidJSON" {
+		return nil, fmt.Errorf("not a CheckInvalidJSON call: %s", query)
+	}
+	if err := call.validate(2); err != nil {
+		return nil, err
+	}
+
+	return db.sampleKeys(ctx, call.Args[0], fmt.Sprintf("`%[1]s` IS NOT NULL AND JSON_VALID(`%[1]s`) = 0", call.Args[1]))
+}
+
+func (db *DB) countIfColumnExists(ctx context.Context, table, column, query string) (int, error) {
+	exists, err := db.columnExists(ctx, table, column)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	return db.RunSelectCountQuery(ctx, query)
+}
+
+func (db *DB) columnExists(ctx context.Context, table, column string) (bool, error) {
+	var columnExists int
+	err := db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE table_name = ? AND table_schema = DATABASE() AND column_name = ?", table, column).Scan(&columnExists)
+	if err != nil {
+		return false, fmt.Errorf("could not check if %s.%s exists: %w", table, column, err)
+	}
+
+	return columnExists > 0, nil
+}
+
+// validate checks the number of arguments and that the table and column
+// names can be safely used as identifiers.
+func (c ProcedureCall) validate(n int) error {
+	if len(c.Args) != n {
+		return fmt.Errorf("%s expects %d arguments, got %d", c.Name, n, len(c.Args))
+	}
+
+	for _, arg := range c.Args[:2] {
+		if !identifierRegex.MatchString(arg) {
+			return fmt.Errorf("invalid identifier %q for %s", arg, c.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseProcedureCall(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantName string
+		wantArgs []string
+		wantOK   bool
+	}{
+		{
+			query:    "CALL ReplaceInvalidJSON('Jobs', 'Data', '{}');",
+			wantName: "ReplaceInvalidJSON",
+			wantArgs: []string{"Jobs", "Data", "{}"},
+			wantOK:   true,
+		},
+		{
+			query:    "call CheckUnsupportedUnicode('Jobs','Data')\n",
+			wantName: "CheckUnsupportedUnicode",
+			wantArgs: []string{"Jobs", "Data"},
+			wantOK:   true,
+		},
+		{
+			query: "SELECT COUNT(*) FROM Audits;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			call, ok := ParseProcedureCall(tt.query)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseProcedureCall() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if call.Name != tt.wantName || !slices.Equal(call.Args, tt.wantArgs) {
+				t.Errorf("ParseProcedureCall() = %+v, want %s%v", call, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
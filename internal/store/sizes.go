@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// TableSize is the size of a table as reported by information_schema. The
+// number of rows is an estimate for InnoDB tables.
+type TableSize struct {
+	Table       string `json:"table"`
+	Rows        int64  `json:"rows"`
+	DataLength  int64  `json:"data_length"`
+	IndexLength int64  `json:"index_length"`
+}
+
+// GetTableSizes returns the sizes of the tables in the database except the
+// excluded ones, the largest tables first.
+func (db *DB) GetTableSizes(ctx context.Context, excluded []string) ([]TableSize, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT TABLE_NAME, COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH, 0), COALESCE(INDEX_LENGTH, 0)
+		FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY DATA_LENGTH + INDEX_LENGTH DESC, TABLE_NAME`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get table sizes: %w", err)
+	}
+	defer rows.Close()
+
+	var sizes []TableSize
+	for rows.Next() {
+		var s TableSize
+		if err = rows.Scan(&s.Table, &s.Rows, &s.DataLength, &s.IndexLength); err != nil {
+			return nil, fmt.Errorf("could not scan table size: %w", err)
+		}
+		if slices.Contains(excluded, s.Table) {
+			continue
+		}
+		sizes = append(sizes, s)
+	}
+
+	return sizes, rows.Err()
+}
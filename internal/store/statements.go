@@ -0,0 +1,98 @@
+package store
+
+import (
+	"strings"
+)
+
+// SplitStatements splits a SQL script into its statements. It is aware of
+// quoted strings and identifiers, comments and Postgres dollar-quoted bodies so
+// that semicolons within them do not end a statement. Empty statements are
+// omitted from the result.
+func SplitStatements(script string) []string {
+	var statements []string
+	var start int
+
+	add := func(end int) {
+		if stmt := strings.TrimSpace(script[start:end]); stmt != "" && !isOnlyComments(stmt) {
+			statements = append(statements, stmt)
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(script, i, c)
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			i = skipUntil(script, i, "\n")
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			i = skipUntil(script, i+1, "*/") + 1
+		case c == '$':
+			if tag, ok := dollarTag(script[i:]); ok {
+				i = skipUntil(script, i+len(tag)-1, tag) + len(tag) - 1
+			}
+		case c == ';':
+			add(i)
+			start = i + 1
+		}
+	}
+	add(len(script))
+
+	return statements
+}
+
+// skipQuoted returns the index of the closing quote of the quoted section
+// starting at i. Doubled quotes are treated as escaped ones.
+func skipQuoted(script string, i int, quote byte) int {
+	for j := i + 1; j < len(script); j++ {
+		if script[j] != quote {
+			continue
+		}
+		if j+1 < len(script) && script[j+1] == quote {
+			j++
+			continue
+		}
+		return j
+	}
+
+	return len(script) - 1
+}
+
+// skipUntil returns the index of the first occurrence of token after i, or the
+// last index of the script if there is none.
+func skipUntil(script string, i int, token string) int {
+	idx := strings.Index(script[i+1:], token)
+	if idx < 0 {
+		return len(script) - 1
+	}
+
+	return i + 1 + idx
+}
+
+// dollarTag returns the dollar quote tag (e.g. $$ or $body$) at the beginning
+// of s, if any.
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1], true
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (j > 1 && c >= '0' && c <= '9'):
+			continue
+		default:
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+func isOnlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "single statement without semicolon",
+			script: "CREATE INDEX idx ON posts(id)",
+			want:   []string{"CREATE INDEX idx ON posts(id)"},
+		},
+		{
+			name:   "multiple statements",
+			script: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want:   []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name:   "semicolons in strings and comments",
+			script: "-- a comment; with a semicolon\nINSERT INTO a VALUES ('x;y', 'it''s');\n/* ; */ SELECT \"a;b\" FROM a;",
+			want:   []string{"-- a comment; with a semicolon\nINSERT INTO a VALUES ('x;y', 'it''s')", "/* ; */ SELECT \"a;b\" FROM a"},
+		},
+		{
+			name: "dollar quoted body",
+			script: `DO $$
+BEGIN
+	IF 1 = 1 THEN
+		ALTER TABLE a ADD COLUMN b int;
+	END IF;
+END $$;
+SELECT $1::text;`,
+			want: []string{"DO $$\nBEGIN\n\tIF 1 = 1 THEN\n\t\tALTER TABLE a ADD COLUMN b int;\n\tEND IF;\nEND $$", "SELECT $1::text"},
+		},
+		{
+			name:   "tagged dollar quote",
+			script: "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;",
+			want:   []string{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql"},
+		},
+		{
+			name:   "only comments",
+			script: "-- morph:nontransactional\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitStatements(tt.script)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SplitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/mattermost/morph"
@@ -23,6 +25,10 @@ import (
 
 const (
 	statementTimeoutInSeconds = 60 * 5 // 5 minutes
+	migrationsTable           = "db_migrations"
+	// migrationsLockKey is the key of the mutex taken by morph in the db_lock
+	// table, the Mattermost server takes the same one to run the migrations.
+	migrationsLockKey = "mm-lock-key"
 )
 
 type DB struct {
@@ -32,8 +38,30 @@ type DB struct {
 	conn         *sql.Conn
 }
 
+// DBConfig is the content of the file written by the mysql command. Apart from
+// the applied migrations, it records where and how the file was produced so
+// that the postgres command can verify it before running the migrations.
 type DBConfig struct {
-	AppliedMigrations []int `json:"applied_migrations"`
+	AppliedMigrations []int         `json:"applied_migrations"`
+	SourceVersion     string        `json:"source_version,omitempty"`
+	SchemaVersion     string        `json:"schema_version,omitempty"`
+	ToolVersion       string        `json:"tool_version,omitempty"`
+	CreatedAt         time.Time     `json:"created_at"`
+	MigrationsHash    string        `json:"migrations_hash,omitempty"`
+	Checks            []CheckResult `json:"checks,omitempty"`
+	Tables            []TableSize   `json:"tables,omitempty"`
+}
+
+type CheckResult struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Fixed    bool   `json:"fixed"`
+	FixError string `json:"fix_error,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+	// Note is a remark which does not require a fix, e.g. a change of the
+	// behavior after the migration.
+	Note string `json:"note,omitempty"`
 }
 
 func NewStore(dbType string, dataSource string) (*DB, error) {
@@ -87,59 +115,164 @@ func (db *DB) ExecQuery(ctx context.Context, query string) error {
 	return err
 }
 
-// RunMigrations will run all of the migrations within a directory,
-func (db *DB) RunEmbeddedMigrations(assets embed.FS, dir string, logger logger.LogInterface) error {
-	queries, err := assets.ReadDir(dir)
+// RunMigrations will run the migrations form a given directory with morph. The
+// migrations are applied while holding the mutex of morph, so that concurrent
+// runs do not apply the same migrations.
+func (db *DB) RunMigrations(src sources.Source) error {
+	driver, err := db.migrationDriver()
 	if err != nil {
 		return err
 	}
 
-	for _, query := range queries {
-		b, err := assets.ReadFile(filepath.Join("post-migrate", query.Name()))
-		if err != nil {
-			return fmt.Errorf("could not read embedded sql file: %w", err)
+	engine, err := morph.New(context.TODO(), driver, src, morph.WithLogger(logger.NewNopLogger()), morph.WithLock(migrationsLockKey))
+	if err != nil {
+		return fmt.Errorf("could not initialize morph: %w", err)
+	}
+	// releases the mutex, the connection pool of the store is left open
+	defer engine.Close()
+
+	err = engine.ApplyAll()
+	if err != nil {
+		return fmt.Errorf("could not apply migrations: %w", err)
+	}
+
+	return nil
+}
+
+// PlanMigrations returns the up migrations from the source that are not
+// applied to the database yet, in the order morph would apply them. Unlike
+// morph, it does not create the migrations table if it does not exist.
+func (db *DB) PlanMigrations(ctx context.Context, src sources.Source) ([]*models.Migration, error) {
+	var applied []string
+
+	exists, err := db.tableExists(ctx, migrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("could not check migrations table: %w", err)
+	}
+
+	if exists {
+		rows, err2 := db.conn.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s", migrationsTable))
+		if err2 != nil {
+			return nil, fmt.Errorf("could not get applied migrations: %w", err2)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err2 = rows.Scan(&name); err2 != nil {
+				return nil, fmt.Errorf("could not scan migration name: %w", err2)
+			}
+			applied = append(applied, name)
 		}
 
-		logger.Printf("applying %s\n", query.Name())
-		err = db.ExecQuery(context.TODO(), string(b))
-		if err != nil {
-			return fmt.Errorf("error during running post-migrate queries: %w", err)
+		if err2 = rows.Err(); err2 != nil {
+			return nil, fmt.Errorf("error during query: %w", err2)
 		}
 	}
 
-	return nil
+	return pendingMigrations(applied, src.Migrations())
 }
 
-// RunMigrations will run the migrations form a given directory with morph
-func (db *DB) RunMigrations(src sources.Source) error {
+// pendingMigrations returns the up migrations of the source which are not
+// applied, the same as morph computes them. Morph refuses to run if more
+// migrations are applied than the source contains, so does the plan.
+func pendingMigrations(applied []string, migrations []*models.Migration) ([]*models.Migration, error) {
+	if len(applied) > len(migrations) {
+		return nil, errors.New("migration mismatch, there are more migrations applied than those were specified in source")
+	}
+
+	names := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		names[name] = true
+	}
+
+	var pending []*models.Migration
+	for _, m := range migrations {
+		if m.Direction != models.Up || names[m.Name] {
+			continue
+		}
+		pending = append(pending, m)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].RawName < pending[j].RawName
+	})
+
+	return pending, nil
+}
+
+// lockMigrations takes the mutex morph takes while applying the migrations
+// and returns the function releasing it.
+func (db *DB) lockMigrations(ctx context.Context) (func(), error) {
+	driver, err := db.migrationDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	var mx drivers.Locker
+	switch db.dbType {
+	case "mysql":
+		mx, err = mysql.NewMutex(migrationsLockKey, driver, logger.NewNopLogger())
+	case "postgres":
+		mx, err = postgres.NewMutex(migrationsLockKey, driver, logger.NewNopLogger())
+	default:
+		err = fmt.Errorf("unsupported db type: %s", db.dbType)
+	}
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("could not create the migrations lock: %w", err)
+	}
+
+	if err = mx.Lock(ctx); err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("could not take the migrations lock: %w", err)
+	}
+
+	return func() {
+		// the lock expires if it can not be removed
+		_ = mx.Unlock()
+		_ = driver.Close()
+	}, nil
+}
+
+func (db *DB) migrationDriver() (drivers.Driver, error) {
 	var driver drivers.Driver
 	var err error
 	switch db.dbType {
 	case "mysql":
 		driver, err = mysql.WithInstance(db.db)
 		if err != nil {
-			return fmt.Errorf("could not initialize driver: %w", err)
+			return nil, fmt.Errorf("could not initialize driver: %w", err)
 		}
 	case "postgres":
 		driver, err = postgres.WithInstance(db.db)
 		if err != nil {
-			return fmt.Errorf("could not initialize driver: %w", err)
+			return nil, fmt.Errorf("could not initialize driver: %w", err)
 		}
 	default:
-		return fmt.Errorf("unsupported db type: %s", db.dbType)
+		return nil, fmt.Errorf("unsupported db type: %s", db.dbType)
 	}
 
-	engine, err := morph.New(context.TODO(), driver, src, morph.WithLogger(logger.NewNopLogger()))
-	if err != nil {
-		return fmt.Errorf("could not initialize morph: %w", err)
+	return driver, nil
+}
+
+func (db *DB) tableExists(ctx context.Context, table string) (bool, error) {
+	var query string
+	switch db.dbType {
+	case "mysql":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	default:
+		return false, fmt.Errorf("unsupported db type: %s", db.dbType)
 	}
 
-	err = engine.ApplyAll()
-	if err != nil {
-		return fmt.Errorf("could not apply migrations: %w", err)
+	var count int
+	if err := db.conn.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
 	}
 
-	return nil
+	return count > 0, nil
 }
 
 func CreateSourceFromEmbedded(assets embed.FS, dir string, versions []int) (sources.Source, error) {
@@ -164,7 +297,11 @@ func CreateSourceFromEmbedded(assets embed.FS, dir string, versions []int) (sour
 
 	assetNames := make([]string, len(versions))
 	for i, v := range versions {
-		assetNames[i] = migrationsShouldBeApplied[v]
+		name, ok := migrationsShouldBeApplied[v]
+		if !ok {
+			return nil, fmt.Errorf("could not find the migration for version %d in %s", v, dir)
+		}
+		assetNames[i] = name
 	}
 
 	res := embedded.Resource(assetNames, func(name string) ([]byte, error) {
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mattermost/morph/models"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	migrations := []*models.Migration{
+		{Name: "create_teams", RawName: "000001_create_teams.up.sql", Version: 1, Direction: models.Up},
+		{Name: "create_teams", RawName: "000001_create_teams.down.sql", Version: 1, Direction: models.Down},
+		{Name: "create_users", RawName: "000003_create_users.up.sql", Version: 3, Direction: models.Up},
+		{Name: "create_posts", RawName: "000002_create_posts.up.sql", Version: 2, Direction: models.Up},
+	}
+
+	pending, err := pendingMigrations([]string{"create_teams"}, migrations)
+	if err != nil {
+		t.Fatalf("pendingMigrations() error = %v, want no error", err)
+	}
+
+	var got []string
+	for _, m := range pending {
+		got = append(got, m.RawName)
+	}
+	if want := []string{"000002_create_posts.up.sql", "000003_create_users.up.sql"}; !slices.Equal(got, want) {
+		t.Errorf("pendingMigrations() = %v, want %v", got, want)
+	}
+
+	applied := []string{"create_teams", "create_posts", "create_users", "create_channels", "create_emojis"}
+	if _, err = pendingMigrations(applied, migrations); err == nil {
+		t.Errorf("pendingMigrations() error = nil, want a mismatch error")
+	}
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	createTableRegex = regexp.MustCompile(`(?is)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(?:\w+\.)?"?(\w+)"?\s*\((.*?)\)\s*;`)
+	alterTableRegex  = regexp.MustCompile(`(?is)ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(?:\w+\.)?"?(\w+)"?\s+(.*?);`)
+	dropTableRegex   = regexp.MustCompile(`(?is)DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:\w+\.)?"?(\w+)"?`)
+
+	columnDefinitionRegex = regexp.MustCompile(`(?is)^"?(\w+)"?\s+(.+)$`)
+	addColumnRegex        = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?"?(\w+)"?\s+(.+)$`)
+	alterColumnTypeRegex  = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?"?(\w+)"?\s+(?:SET\s+DATA\s+)?TYPE\s+(.+)$`)
+	dropColumnRegex       = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?"?(\w+)"?`)
+	renameColumnRegex     = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?"?(\w+)"?\s+TO\s+"?(\w+)"?`)
+	varcharTypeRegex      = regexp.MustCompile(`(?i)^(?:varchar|character\s+varying)\s*\(\s*(\d+)\s*\)`)
+
+	// tableConstraintKeywords start the entries of a table definition which
+	// are not columns.
+	tableConstraintKeywords = []string{"constraint", "primary", "unique", "check", "foreign", "exclude"}
+)
+
+// VarcharLimit is the length limit of a varchar column in Postgres.
+type VarcharLimit struct {
+	Table  string
+	Column string
+	Length int
+}
+
+// VarcharLimits follows the up migrations of the given versions found in dir
+// and returns the varchar columns of the resulting schema. All of the up
+// migrations are followed if versions is empty. Table and column names are
+// lower case as Postgres folds the unquoted identifiers.
+func VarcharLimits(assets fs.FS, dir string, versions []int) ([]VarcharLimit, error) {
+	migrations, err := upMigrations(assets, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	sorted := make([]int, 0, len(migrations))
+	for v := range migrations {
+		if len(versions) == 0 || slices.Contains(versions, v) {
+			sorted = append(sorted, v)
+		}
+	}
+	slices.Sort(sorted)
+
+	schema := make(map[string]map[string]int)
+	for _, v := range sorted {
+		b, err2 := fs.ReadFile(assets, path.Join(dir, migrations[v].RawName))
+		if err2 != nil {
+			return nil, fmt.Errorf("could not read migration: %w", err2)
+		}
+		applyVarcharChanges(schema, string(b))
+	}
+
+	var limits []VarcharLimit
+	for table, columns := range schema {
+		for column, length := range columns {
+			limits = append(limits, VarcharLimit{Table: table, Column: column, Length: length})
+		}
+	}
+	sort.Slice(limits, func(i, j int) bool {
+		if limits[i].Table != limits[j].Table {
+			return limits[i].Table < limits[j].Table
+		}
+		return limits[i].Column < limits[j].Column
+	})
+
+	return limits, nil
+}
+
+// applyVarcharChanges applies the table definitions and alterations of the
+// script to the schema in the order they appear. The statements within DO
+// blocks are applied as well, regardless of their conditions.
+func applyVarcharChanges(schema map[string]map[string]int, script string) {
+	type match struct {
+		pos  int
+		kind string
+		sub  []string
+	}
+
+	var matches []match
+	for kind, re := range map[string]*regexp.Regexp{"create": createTableRegex, "alter": alterTableRegex, "drop": dropTableRegex} {
+		for _, idx := range re.FindAllStringSubmatchIndex(script, -1) {
+			sub := make([]string, 0, len(idx)/2)
+			for i := 0; i < len(idx); i += 2 {
+				sub = append(sub, script[idx[i]:idx[i+1]])
+			}
+			matches = append(matches, match{pos: idx[0], kind: kind, sub: sub})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+
+	for _, m := range matches {
+		table := strings.ToLower(m.sub[1])
+		switch m.kind {
+		case "create":
+			if _, ok := schema[table]; ok {
+				// CREATE TABLE IF NOT EXISTS does not change an existing table
+				continue
+			}
+			schema[table] = make(map[string]int)
+			for _, def := range splitTopLevel(m.sub[2]) {
+				if slices.Contains(tableConstraintKeywords, strings.ToLower(strings.Fields(def + " ")[0])) {
+					continue
+				}
+				if cm := columnDefinitionRegex.FindStringSubmatch(def); cm != nil {
+					setVarcharLimit(schema, table, cm[1], cm[2])
+				}
+			}
+		case "alter":
+			for _, action := range splitTopLevel(m.sub[2]) {
+				switch {
+				case addColumnRegex.MatchString(action):
+					am := addColumnRegex.FindStringSubmatch(action)
+					if _, ok := schema[table][strings.ToLower(am[1])]; ok {
+						continue
+					}
+					setVarcharLimit(schema, table, am[1], am[2])
+				case alterColumnTypeRegex.MatchString(action):
+					am := alterColumnTypeRegex.FindStringSubmatch(action)
+					setVarcharLimit(schema, table, am[1], am[2])
+				case dropColumnRegex.MatchString(action):
+					am := dropColumnRegex.FindStringSubmatch(action)
+					delete(schema[table], strings.ToLower(am[1]))
+				case renameColumnRegex.MatchString(action):
+					am := renameColumnRegex.FindStringSubmatch(action)
+					if length, ok := schema[table][strings.ToLower(am[1])]; ok {
+						delete(schema[table], strings.ToLower(am[1]))
+						schema[table][strings.ToLower(am[2])] = length
+					}
+				}
+			}
+		case "drop":
+			delete(schema, table)
+		}
+	}
+}
+
+// setVarcharLimit records the limit of the column if its type is varchar,
+// otherwise the column is removed from the schema.
+func setVarcharLimit(schema map[string]map[string]int, table, column, typ string) {
+	if schema[table] == nil {
+		schema[table] = make(map[string]int)
+	}
+
+	column = strings.ToLower(column)
+	vm := varcharTypeRegex.FindStringSubmatch(strings.TrimSpace(typ))
+	if vm == nil {
+		delete(schema[table], column)
+		return
+	}
+
+	length, err := strconv.Atoi(vm[1])
+	if err != nil {
+		delete(schema[table], column)
+		return
+	}
+	schema[table][column] = length
+}
+
+// VarcharColumn is a MySQL column which has a varchar limit in Postgres.
+type VarcharColumn struct {
+	Table  string
+	Column string
+	Length int
+}
+
+// GetVarcharColumns returns the MySQL columns of the given limits which can
+// hold longer values than the limit. The columns whose maximum length is
+// within the limit, and the ones that do not exist, are omitted.
+func (db *DB) GetVarcharColumns(ctx context.Context, limits []VarcharLimit) ([]VarcharColumn, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT TABLE_NAME, COLUMN_NAME, CHARACTER_MAXIMUM_LENGTH FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND CHARACTER_MAXIMUM_LENGTH IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get columns: %w", err)
+	}
+	defer rows.Close()
+
+	type column struct {
+		table, name string
+		maxLength   int64
+	}
+	columns := make(map[string]column)
+	for rows.Next() {
+		var c column
+		if err = rows.Scan(&c.table, &c.name, &c.maxLength); err != nil {
+			return nil, fmt.Errorf("could not scan column: %w", err)
+		}
+		columns[strings.ToLower(c.table+"."+c.name)] = c
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get columns: %w", err)
+	}
+
+	var result []VarcharColumn
+	for _, l := range limits {
+		c, ok := columns[l.Table+"."+l.Column]
+		if !ok || c.maxLength <= int64(l.Length) {
+			continue
+		}
+		result = append(result, VarcharColumn{Table: c.table, Column: c.name, Length: l.Length})
+	}
+
+	return result, nil
+}
+
+// CountVarcharOverflow returns the number of rows having longer values in the
+// column than its limit in Postgres. Postgres limits the number of characters
+// rather than bytes.
+func (db *DB) CountVarcharOverflow(ctx context.Context, column VarcharColumn) (int, error) {
+	return db.RunSelectCountQuery(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE CHAR_LENGTH(`%s`) > %d", column.Table, column.Column, column.Length))
+}
+
+// FixVarcharOverflow applies the strategy to the rows counted by
+// CountVarcharOverflow. Truncation keeps the leading characters of the values
+// that fit into the limit.
+func (db *DB) FixVarcharOverflow(ctx context.Context, column VarcharColumn, strategy VarcharStrategy) (int64, error) {
+	var query string
+	switch strategy {
+	case VarcharDelete:
+		query = fmt.Sprintf("DELETE FROM `%s` WHERE CHAR_LENGTH(`%s`) > %d", column.Table, column.Column, column.Length)
+	case VarcharTruncate:
+		query = fmt.Sprintf("UPDATE `%[1]s` SET `%[2]s` = LEFT(`%[2]s`, %[3]d) WHERE CHAR_LENGTH(`%[2]s`) > %[3]d", column.Table, column.Column, column.Length)
+	default:
+		return 0, fmt.Errorf("unsupported varchar strategy: %s", strategy)
+	}
+
+	res, err := db.conn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("could not %s the values of %s.%s: %w", strategy, column.Table, column.Column, err)
+	}
+
+	return res.RowsAffected()
+}
+
+// VarcharStrategy is the way the values overflowing a varchar column are fixed.
+type VarcharStrategy string
+
+const (
+	// VarcharDelete deletes the rows with longer values.
+	VarcharDelete VarcharStrategy = "delete"
+	// VarcharTruncate truncates the longer values to the limit.
+	VarcharTruncate VarcharStrategy = "truncate"
+	// VarcharSkip leaves the rows as they are.
+	VarcharSkip VarcharStrategy = "skip"
+)
+
+// defaultVarcharStrategies are the strategies of the tables whose rows can be
+// deleted or truncated without losing data that Mattermost depends on. The
+// other tables are skipped by default, their values need to be fixed by hand or
+// with an explicit strategy.
+var defaultVarcharStrategies = map[string]VarcharStrategy{
+	// logs and state that are written again by the server
+	"audits":           VarcharDelete,
+	"clusterdiscovery": VarcharDelete,
+	"linkmetadata":     VarcharDelete,
+	"sessions":         VarcharDelete,
+	"uploadsessions":   VarcharDelete,
+	// descriptive values that are not used as references
+	"commands":    VarcharTruncate,
+	"compliances": VarcharTruncate,
+}
+
+// VarcharStrategies are the strategies to fix the varchar overflows with.
+type VarcharStrategies struct {
+	// Default is used for the tables and columns without an override. If it
+	// is empty, the default of the table is used.
+	Default VarcharStrategy
+	// Overrides are keyed by the lower case table or table.column names.
+	Overrides map[string]VarcharStrategy
+}
+
+// ParseVarcharStrategies parses values in the form of strategy, table=strategy
+// or table.column=strategy. The value without a table sets the default.
+func ParseVarcharStrategies(values []string) (VarcharStrategies, error) {
+	strategies := VarcharStrategies{Overrides: make(map[string]VarcharStrategy)}
+	for _, value := range values {
+		target, name, found := strings.Cut(value, "=")
+		if !found {
+			name, target = target, ""
+		}
+
+		strategy := VarcharStrategy(strings.ToLower(strings.TrimSpace(name)))
+		switch strategy {
+		case VarcharDelete, VarcharTruncate, VarcharSkip:
+		default:
+			return strategies, fmt.Errorf("invalid varchar strategy %q, should be one of delete, truncate or skip", name)
+		}
+
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target == "" {
+			strategies.Default = strategy
+			continue
+		}
+		strategies.Overrides[target] = strategy
+	}
+
+	return strategies, nil
+}
+
+// For returns the strategy of the column. The override of the column takes
+// precedence over the override of the table, which takes precedence over the
+// default.
+func (s VarcharStrategies) For(column VarcharColumn) VarcharStrategy {
+	table := strings.ToLower(column.Table)
+	if strategy, ok := s.Overrides[table+"."+strings.ToLower(column.Column)]; ok {
+		return strategy
+	}
+	if strategy, ok := s.Overrides[table]; ok {
+		return strategy
+	}
+	if s.Default != "" {
+		return s.Default
+	}
+	if strategy, ok := defaultVarcharStrategies[table]; ok {
+		return strategy
+	}
+
+	return VarcharSkip
+}
+
+// VarcharFixPrivileges returns the privileges required to fix the rows
+// overflowing the given columns with the strategies.
+func VarcharFixPrivileges(columns []VarcharColumn, strategies VarcharStrategies) []TablePrivilege {
+	var privileges []TablePrivilege
+	for _, c := range columns {
+		var p TablePrivilege
+		switch strategies.For(c) {
+		case VarcharDelete:
+			p = TablePrivilege{Privilege: "DELETE", Table: c.Table}
+		case VarcharTruncate:
+			p = TablePrivilege{Privilege: "UPDATE", Table: c.Table}
+		default:
+			continue
+		}
+		if !slices.Contains(privileges, p) {
+			privileges = append(privileges, p)
+		}
+	}
+
+	return privileges
+}
+
+// splitTopLevel splits s by the commas which are not within parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	var depth, start int
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+
+	return parts
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVarcharLimits(t *testing.T) {
+	assets := fstest.MapFS{
+		"postgres/000001_create_teams.up.sql": {Data: []byte(`CREATE TABLE IF NOT EXISTS teams (
+    id VARCHAR(26) PRIMARY KEY,
+    name varchar(64),
+    description character varying(255),
+    email VARCHAR(128) DEFAULT '',
+    createat bigint,
+    UNIQUE (name)
+);`)},
+		"postgres/000001_create_teams.down.sql": {Data: []byte(`DROP TABLE IF EXISTS teams;`)},
+		"postgres/000002_alter_teams.up.sql": {Data: []byte(`ALTER TABLE teams ADD COLUMN IF NOT EXISTS inviteid varchar(32);
+ALTER TABLE teams ALTER COLUMN description TYPE varchar(512), DROP COLUMN IF EXISTS email;
+DO $$
+BEGIN
+    ALTER TABLE teams ALTER COLUMN name TYPE text;
+END $$;`)},
+		"postgres/000003_create_audits.up.sql": {Data: []byte(`CREATE TABLE IF NOT EXISTS audits (
+    id VARCHAR(26) PRIMARY KEY,
+    action VARCHAR(512)
+);
+ALTER TABLE audits RENAME COLUMN action TO operation;`)},
+	}
+
+	tests := []struct {
+		name     string
+		versions []int
+		want     []VarcharLimit
+	}{
+		{
+			name: "all migrations",
+			want: []VarcharLimit{
+				{Table: "audits", Column: "id", Length: 26},
+				{Table: "audits", Column: "operation", Length: 512},
+				{Table: "teams", Column: "description", Length: 512},
+				{Table: "teams", Column: "id", Length: 26},
+				{Table: "teams", Column: "inviteid", Length: 32},
+			},
+		},
+		{
+			name:     "applied migrations",
+			versions: []int{1},
+			want: []VarcharLimit{
+				{Table: "teams", Column: "description", Length: 255},
+				{Table: "teams", Column: "email", Length: 128},
+				{Table: "teams", Column: "id", Length: 26},
+				{Table: "teams", Column: "name", Length: 64},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VarcharLimits(assets, "postgres", tt.versions)
+			if err != nil {
+				t.Fatalf("VarcharLimits() error = %v, want no error", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("VarcharLimits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVarcharStrategies(t *testing.T) {
+	strategies, err := ParseVarcharStrategies([]string{"audits=truncate", "Posts.Type=skip", "posts=delete"})
+	if err != nil {
+		t.Fatalf("ParseVarcharStrategies() error = %v, want no error", err)
+	}
+
+	tests := []struct {
+		column VarcharColumn
+		want   VarcharStrategy
+	}{
+		{column: VarcharColumn{Table: "Posts", Column: "Type"}, want: VarcharSkip},
+		{column: VarcharColumn{Table: "Posts", Column: "Hashtags"}, want: VarcharDelete},
+		{column: VarcharColumn{Table: "Audits", Column: "Action"}, want: VarcharTruncate},
+		{column: VarcharColumn{Table: "ClusterDiscovery", Column: "Hostname"}, want: VarcharDelete},
+		{column: VarcharColumn{Table: "Commands", Column: "IconURL"}, want: VarcharTruncate},
+		{column: VarcharColumn{Table: "FileInfo", Column: "Name"}, want: VarcharSkip},
+	}
+	for _, tt := range tests {
+		if got := strategies.For(tt.column); got != tt.want {
+			t.Errorf("For(%s.%s) = %s, want %s", tt.column.Table, tt.column.Column, got, tt.want)
+		}
+	}
+
+	strategies, err = ParseVarcharStrategies([]string{"skip", "audits=delete"})
+	if err != nil {
+		t.Fatalf("ParseVarcharStrategies() error = %v, want no error", err)
+	}
+	if got := strategies.For(VarcharColumn{Table: "ClusterDiscovery", Column: "Hostname"}); got != VarcharSkip {
+		t.Errorf("For(ClusterDiscovery.Hostname) = %s, want %s", got, VarcharSkip)
+	}
+
+	if _, err = ParseVarcharStrategies([]string{"audits=drop"}); err == nil {
+		t.Errorf("ParseVarcharStrategies() error = nil, want an error")
+	}
+}
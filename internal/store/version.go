@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/mattermost/migration-assist/internal/logger"
+)
+
+// Release maps a Mattermost release to the latest migration version it ships.
+type Release struct {
+	Version   string `json:"version"`
+	Migration int    `json:"migration"`
+}
+
+// LoadReleases reads the release table from the given file and returns it
+// sorted by the migration version.
+func LoadReleases(assets fs.FS, name string) ([]Release, error) {
+	b, err := fs.ReadFile(assets, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read releases: %w", err)
+	}
+
+	var releases []Release
+	if err = json.Unmarshal(b, &releases); err != nil {
+		return nil, fmt.Errorf("could not decode releases: %w", err)
+	}
+
+	slices.SortStableFunc(releases, func(a, b Release) int {
+		return a.Migration - b.Migration
+	})
+
+	return releases, nil
+}
+
+// ReleaseForMigration returns the earliest release shipping the given
+// migration version. False is returned if the migration is newer than any of
+// the known releases.
+func ReleaseForMigration(releases []Release, migration int) (semver.Version, bool) {
+	for _, r := range releases {
+		if r.Migration < migration {
+			continue
+		}
+
+		v, err := semver.ParseTolerant(r.Version)
+		if err != nil {
+			continue
+		}
+
+		return v, true
+	}
+
+	return semver.Version{}, false
+}
+
+// DetectMattermostVersion infers the Mattermost version of a MySQL database.
+// The version stored in the Systems table is preferred, the latest applied
+// migration is used to determine the release if it is not available.
+func (db *DB) DetectMattermostVersion(ctx context.Context, releases []Release, baseLogger logger.LogInterface) (semver.Version, error) {
+	systemVersion, err := db.GetSystemValue(ctx, "Version")
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("could not get the version from Systems table: %w", err)
+	}
+
+	lastSecurityTime, err := db.GetSystemValue(ctx, "LastSecurityTime")
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("could not get the last security time from Systems table: %w", err)
+	}
+	if systemVersion == "" && lastSecurityTime == "" {
+		baseLogger.Println("Systems table does not contain any version information, the server may have never been started.")
+	}
+
+	applied, err := db.GetAppliedMigrations(ctx)
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	var latest int
+	if len(applied) > 0 {
+		latest = slices.Max(applied)
+	}
+	migrationRelease, known := ReleaseForMigration(releases, latest)
+
+	if systemVersion != "" {
+		v, err2 := semver.ParseTolerant(systemVersion)
+		if err2 != nil {
+			return semver.Version{}, fmt.Errorf("could not parse the version %q from Systems table: %w", systemVersion, err2)
+		}
+		v.Pre, v.Build = nil, nil
+
+		if known && migrationRelease.GT(semver.Version{Major: v.Major, Minor: v.Minor}) {
+			baseLogger.Printf("latest applied migration (%d) belongs to %s while Systems table reports %s\n", latest, migrationRelease.String(), v.String())
+		}
+
+		return v, nil
+	}
+
+	if latest == 0 {
+		return semver.Version{}, fmt.Errorf("could not detect the Mattermost version, no migrations are applied")
+	}
+	if !known {
+		return semver.Version{}, fmt.Errorf("could not detect the Mattermost version, migration %d is newer than the known releases", latest)
+	}
+
+	return migrationRelease, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/mattermost/migration-assist/queries"
+)
+
+func TestReleaseForMigration(t *testing.T) {
+	releases, err := LoadReleases(queries.Assets(), "releases.json")
+	if err != nil {
+		t.Fatalf("LoadReleases() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		migration int
+		want      string
+		wantKnown bool
+	}{
+		{
+			name:      "exact match",
+			migration: 111,
+			want:      "8.0.0",
+			wantKnown: true,
+		},
+		{
+			name:      "between releases",
+			migration: 110,
+			want:      "8.0.0",
+			wantKnown: true,
+		},
+		{
+			name:      "release sharing the migration with a later one",
+			migration: 124,
+			want:      "9.10.0",
+			wantKnown: true,
+		},
+		{
+			name:      "unknown migration",
+			migration: 10000,
+			wantKnown: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, known := ReleaseForMigration(releases, tt.migration)
+			if known != tt.wantKnown {
+				t.Fatalf("ReleaseForMigration() known = %v, want %v", known, tt.wantKnown)
+			}
+			if known && v.String() != tt.want {
+				t.Errorf("ReleaseForMigration() = %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('ChannelMembers', 'NotifyProps');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('focalboard_sessions', 'props');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('focalboard_users', 'props');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('IR_Incident', 'ChecklistsJSON');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('IR_Playbook', 'ChecklistsJSON');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('Jobs', 'Data');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('LinkMetadata', 'Data');
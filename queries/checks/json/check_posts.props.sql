@@ -0,0 +1 @@
+CALL CheckInvalidJSON('Posts', 'Props');
@@ -0,0 +1 @@
+CALL CheckInvalidJSON('RecentSearches', 'Query');
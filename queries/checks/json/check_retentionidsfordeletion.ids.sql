@@ -0,0 +1 @@
+CALL CheckInvalidJSON('RetentionIdsForDeletion', 'Ids');
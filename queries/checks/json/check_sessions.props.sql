@@ -0,0 +1 @@
+CALL CheckInvalidJSON('Sessions', 'Props');
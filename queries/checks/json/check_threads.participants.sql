@@ -0,0 +1 @@
+CALL CheckInvalidJSON('Threads', 'Participants');
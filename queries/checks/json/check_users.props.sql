@@ -0,0 +1 @@
+CALL CheckInvalidJSON('Users', 'Props');
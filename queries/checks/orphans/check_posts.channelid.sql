@@ -0,0 +1 @@
+SELECT COUNT(*) FROM Posts WHERE NOT EXISTS (SELECT 1 FROM Channels p WHERE p.Id = Posts.ChannelId);
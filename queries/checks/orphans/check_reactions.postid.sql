@@ -0,0 +1 @@
+SELECT COUNT(*) FROM Reactions WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = Reactions.PostId);
@@ -0,0 +1 @@
+SELECT COUNT(*) FROM ThreadMemberships WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = ThreadMemberships.PostId);
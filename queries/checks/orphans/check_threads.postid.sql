@@ -0,0 +1 @@
+SELECT COUNT(*) FROM Threads WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = Threads.PostId);
@@ -1 +0,0 @@
-CALL CountIfExists('Compliances', 'Emails', 1024);
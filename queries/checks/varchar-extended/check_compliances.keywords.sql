@@ -1 +0,0 @@
-CALL CountIfExists('Compliances', 'Keywords', 512);
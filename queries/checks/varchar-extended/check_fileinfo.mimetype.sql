@@ -1 +0,0 @@
-CALL CountIfExists('FileInfo', 'MimeType', 256);
@@ -1 +0,0 @@
-CALL CountIfExists('FileInfo', 'Name', 256);
@@ -1 +0,0 @@
-CALL CountIfExists('FileInfo', 'Path', 512);
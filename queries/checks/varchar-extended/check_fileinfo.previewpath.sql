@@ -1 +0,0 @@
-CALL CountIfExists('FileInfo', 'PreviewPath', 512);
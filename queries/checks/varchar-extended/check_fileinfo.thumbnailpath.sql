@@ -1 +0,0 @@
-CALL CountIfExists('FileInfo', 'ThumbnailPath', 512);
@@ -1 +0,0 @@
-CALL CountIfExists('LinkMetadata', 'URL', 2048);
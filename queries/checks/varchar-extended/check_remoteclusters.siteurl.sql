@@ -1 +0,0 @@
-CALL CountIfExists('RemoteClusters', 'SiteURL', 512);
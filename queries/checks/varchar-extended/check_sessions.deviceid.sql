@@ -1 +0,0 @@
-CALL CountIfExists('Sessions', 'DeviceId', 512);
@@ -1 +0,0 @@
-CALL CountIfExists('UploadSessions', 'FileName', 256);
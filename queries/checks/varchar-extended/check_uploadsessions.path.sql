@@ -1 +0,0 @@
-CALL CountIfExists('UploadSessions', 'Path', 512);
@@ -1 +0,0 @@
-CALL CountIfExists('Audits', 'Action', 512);
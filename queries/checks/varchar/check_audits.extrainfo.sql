@@ -1 +0,0 @@
-CALL CountIfExists('Audits', 'ExtraInfo', 1024);
@@ -1 +0,0 @@
-CALL CountIfExists('ClusterDiscovery', 'HostName', 512);
@@ -1 +0,0 @@
-CALL CountIfExists('Commands', 'AutoCompleteDesc', 1024);
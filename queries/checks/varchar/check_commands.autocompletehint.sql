@@ -1 +0,0 @@
-CALL CountIfExists('Commands', 'AutoCompleteHint', 1024);
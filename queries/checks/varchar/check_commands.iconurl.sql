@@ -1 +0,0 @@
-CALL CountIfExists('Commands', 'IconURL', 1024);
@@ -1 +0,0 @@
-CALL CountIfExists('RemoteClusters', 'Topics', 512);
@@ -1 +0,0 @@
-CALL CountIfExists('Systems', 'Value', 1024);
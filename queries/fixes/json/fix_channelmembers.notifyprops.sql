@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('ChannelMembers', 'NotifyProps', '{}');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('focalboard_blocks_history', 'fields', '{}');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('focalboard_users', 'props', '{}');
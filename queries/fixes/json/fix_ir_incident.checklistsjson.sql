@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('IR_Incident', 'ChecklistsJSON', '[]');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('IR_Playbook', 'ChecklistsJSON', '[]');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('Jobs', 'Data', '{}');
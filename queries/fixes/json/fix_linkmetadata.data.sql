@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('LinkMetadata', 'Data', '{}');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('Posts', 'Props', '{}');
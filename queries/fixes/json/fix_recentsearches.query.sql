@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('RecentSearches', 'Query', '[]');
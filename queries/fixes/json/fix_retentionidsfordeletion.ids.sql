@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('RetentionIdsForDeletion', 'Ids', '[]');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('Sessions', 'Props', '{}');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('Threads', 'Participants', '[]');
@@ -0,0 +1 @@
+CALL ReplaceInvalidJSON('Users', 'Props', '{}');
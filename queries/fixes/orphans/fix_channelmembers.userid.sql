@@ -0,0 +1 @@
+DELETE FROM ChannelMembers WHERE NOT EXISTS (SELECT 1 FROM Users p WHERE p.Id = ChannelMembers.UserId);
@@ -0,0 +1 @@
+DELETE FROM FileInfo WHERE NOT EXISTS (SELECT 1 FROM Posts p JOIN Channels c ON c.Id = p.ChannelId WHERE p.Id = FileInfo.PostId) AND FileInfo.PostId != '';
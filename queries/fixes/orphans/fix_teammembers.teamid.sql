@@ -0,0 +1 @@
+DELETE FROM TeamMembers WHERE NOT EXISTS (SELECT 1 FROM Teams p WHERE p.Id = TeamMembers.TeamId);
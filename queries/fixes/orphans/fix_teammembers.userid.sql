@@ -0,0 +1 @@
+DELETE FROM TeamMembers WHERE NOT EXISTS (SELECT 1 FROM Users p WHERE p.Id = TeamMembers.UserId);
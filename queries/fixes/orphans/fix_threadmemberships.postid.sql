@@ -0,0 +1 @@
+DELETE FROM ThreadMemberships WHERE NOT EXISTS (SELECT 1 FROM Posts p WHERE p.Id = ThreadMemberships.PostId);
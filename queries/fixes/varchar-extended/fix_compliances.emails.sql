@@ -1 +0,0 @@
-DELETE FROM Compliances WHERE LENGTH(Emails) > 1024;
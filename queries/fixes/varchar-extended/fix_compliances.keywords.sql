@@ -1 +0,0 @@
-DELETE FROM Compliances WHERE LENGTH(Keywords) > 512;
@@ -1 +0,0 @@
-DELETE FROM FileInfo WHERE LENGTH(Name) > 256;
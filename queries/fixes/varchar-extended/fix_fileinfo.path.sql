@@ -1 +0,0 @@
-DELETE FROM FileInfo WHERE LENGTH(Path) > 512;
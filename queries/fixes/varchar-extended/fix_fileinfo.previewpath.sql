@@ -1 +0,0 @@
-DELETE FROM FileInfo WHERE LENGTH(PreviewPath) > 512;
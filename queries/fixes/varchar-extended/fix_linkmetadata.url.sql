@@ -1 +0,0 @@
-DELETE FROM LinkMetadata WHERE LENGTH(URL) > 2048;
@@ -1 +0,0 @@
-DELETE FROM RemoteClusters WHERE LENGTH(SiteURL) > 512;
@@ -1 +0,0 @@
-DELETE FROM Sessions WHERE LENGTH(DeviceId) > 512;
@@ -1 +0,0 @@
-DELETE FROM UploadSessions WHERE LENGTH(FileName) > 256;
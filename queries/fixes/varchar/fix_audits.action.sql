@@ -1 +0,0 @@
-DELETE FROM Audits WHERE LENGTH(Action) > 512;
@@ -1 +0,0 @@
-DELETE FROM Audits WHERE LENGTH(ExtraInfo) > 1024;
@@ -1 +0,0 @@
-DELETE FROM ClusterDiscovery WHERE LENGTH(HostName) > 512;
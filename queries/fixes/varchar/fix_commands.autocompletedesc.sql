@@ -1 +0,0 @@
-DELETE FROM Commands WHERE LENGTH(AutoCompleteDesc) > 1024;
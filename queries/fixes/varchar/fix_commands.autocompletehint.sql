@@ -1 +0,0 @@
-DELETE FROM Commands WHERE LENGTH(AutoCompleteHint) > 1024;
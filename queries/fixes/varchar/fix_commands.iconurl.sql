@@ -1 +0,0 @@
-SELECT COUNT(*) FROM Commands WHERE LENGTH(IconURL) > 1024;
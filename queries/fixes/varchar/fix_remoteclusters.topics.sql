@@ -1 +0,0 @@
-SELECT COUNT(*) FROM RemoteClusters WHERE LENGTH(Topics) > 512;
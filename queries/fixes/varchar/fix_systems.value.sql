@@ -1 +0,0 @@
-SELECT COUNT(*) FROM Systems WHERE LENGTH(Value) > 1024;
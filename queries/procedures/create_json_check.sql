@@ -1,6 +1,6 @@
-DROP PROCEDURE IF EXISTS CountIfExists;
+DROP PROCEDURE IF EXISTS CheckInvalidJSON;
 
-CREATE PROCEDURE CountIfExists(tableName TEXT, colName TEXT, len TEXT)
+CREATE PROCEDURE CheckInvalidJSON(tableName text, colName text)
 BEGIN
 	DECLARE columnExists INT;
 
@@ -11,7 +11,7 @@ BEGIN
     AND column_name =  colName;
 
 	IF columnExists > 0 THEN
-		SET @s = CONCAT('SELECT COUNT(*) FROM ', tableName, ' WHERE LENGTH(', colName, ') > ', len);
+		SET @s = CONCAT('SELECT COUNT(*) FROM ', tableName, ' WHERE ', colName, ' IS NOT NULL AND JSON_VALID(', colName, ') = 0');
 
 		PREPARE stmt1 FROM @s;
 		EXECUTE stmt1;
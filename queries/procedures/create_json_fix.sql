@@ -0,0 +1,21 @@
+DROP PROCEDURE IF EXISTS ReplaceInvalidJSON;
+
+CREATE PROCEDURE ReplaceInvalidJSON(tableName text, colName text, defaultValue text)
+BEGIN
+	DECLARE columnExists INT;
+
+	-- check if column exists
+	SELECT COUNT(*) INTO columnExists FROM INFORMATION_SCHEMA.COLUMNS
+    WHERE table_name = tableName
+    AND table_schema = DATABASE()
+    AND column_name =  colName;
+
+	IF columnExists > 0 THEN
+		SET @s = CONCAT('UPDATE ', tableName, ' SET ', colName, ' = ? WHERE ', colName, ' IS NOT NULL AND JSON_VALID(', colName, ') = 0');
+		SET @v = defaultValue;
+
+		PREPARE stmt1 FROM @s;
+		EXECUTE stmt1 USING @v;
+		DEALLOCATE PREPARE stmt1;
+	END IF;
+END;
@@ -1 +0,0 @@
-DROP PROCEDURE IF EXISTS CountIfExists;